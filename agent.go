@@ -0,0 +1,104 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : agent.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 10:41:55
+ * Description  : Loading generated keys into a running ssh-agent
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Options for adding a key to the agent
+type agentOptions struct {
+	socket   string        // agent socket path; SSH_AUTH_SOCK when empty
+	lifetime time.Duration // zero means no lifetime constraint
+	confirm  bool          // require confirmation before each use
+}
+
+// Agent result message
+type agentAddedMsg struct {
+	err error
+}
+
+// addKeyToAgent loads a private key into the ssh-agent listening on the
+// configured socket, applying the lifetime and confirm-before-use constraints.
+func addKeyToAgent(priv interface{}, comment string, opts agentOptions) error {
+	socket := opts.socket
+	if socket == "" {
+		socket = os.Getenv("SSH_AUTH_SOCK")
+	}
+	if socket == "" {
+		return errors.New("SSH_AUTH_SOCK is not set; is ssh-agent running?")
+	}
+	if opts.lifetime < 0 {
+		return fmt.Errorf("invalid agent lifetime %s", opts.lifetime)
+	}
+	secs := math.Ceil(opts.lifetime.Seconds())
+	if secs > math.MaxUint32 {
+		return fmt.Errorf("agent lifetime %s is too long", opts.lifetime)
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return fmt.Errorf("connecting to ssh-agent: %w", err)
+	}
+	defer conn.Close()
+
+	err = agent.NewClient(conn).Add(agent.AddedKey{
+		PrivateKey:       priv,
+		Comment:          comment,
+		LifetimeSecs:     uint32(secs),
+		ConfirmBeforeUse: opts.confirm,
+	})
+	if err != nil {
+		return fmt.Errorf("adding key to ssh-agent: %w", err)
+	}
+	return nil
+}
+
+// addKeyToAgentCmd adds the key in the background for the TUI success screen.
+func addKeyToAgentCmd(priv interface{}, comment string, opts agentOptions) tea.Cmd {
+	return func() tea.Msg {
+		return agentAddedMsg{err: addKeyToAgent(priv, comment, opts)}
+	}
+}
+
+// Lifetimes offered on the TUI success screen; zero means no limit
+var agentLifetimes = []time.Duration{0, time.Hour, 8 * time.Hour, 24 * time.Hour}
+
+// agentConstraintsView renders the lifetime and confirm settings used when the
+// key is added from the success screen.
+func (m model) agentConstraintsView(pad string) string {
+	lifetime := "no limit"
+	if d := agentLifetimes[m.agentLifetime]; d > 0 {
+		lifetime = strings.TrimSuffix(d.String(), "0m0s")
+	}
+	confirm := " "
+	if m.agentConfirm {
+		confirm = "x"
+	}
+	return pad + "    Lifetime: " + lifetime + " (press l to change)" + "\n" +
+		pad + fmt.Sprintf("    [%s] Confirm before each use (press c)", confirm) + "\n"
+}
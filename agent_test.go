@@ -0,0 +1,135 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : agent_test.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 23:02:44
+ * Description  : Tests for loading keys into ssh-agent
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// recordingAgent keeps the constraints of every key added to the keyring,
+// which does not expose them itself.
+type recordingAgent struct {
+	agent.Agent
+	mu    sync.Mutex
+	added []agent.AddedKey
+}
+
+func (a *recordingAgent) Add(key agent.AddedKey) error {
+	a.mu.Lock()
+	a.added = append(a.added, key)
+	a.mu.Unlock()
+	return a.Agent.Add(key)
+}
+
+// serveAgent serves a keyring on a socket under t.TempDir.
+func serveAgent(t *testing.T) (*recordingAgent, string) {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	a := &recordingAgent{Agent: agent.NewKeyring()}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				agent.ServeAgent(a, conn)
+			}()
+		}
+	}()
+	return a, socket
+}
+
+func TestAddKeyToAgentConstraints(t *testing.T) {
+	a, socket := serveAgent(t)
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := agentOptions{socket: socket, lifetime: 90 * time.Second, confirm: true}
+	if err := addKeyToAgent(priv, "test@host", opts); err != nil {
+		t.Fatalf("addKeyToAgent: %v", err)
+	}
+
+	a.mu.Lock()
+	added := a.added
+	a.mu.Unlock()
+	if len(added) != 1 {
+		t.Fatalf("agent received %d keys, want 1", len(added))
+	}
+	if added[0].LifetimeSecs != 90 {
+		t.Errorf("LifetimeSecs = %d, want 90", added[0].LifetimeSecs)
+	}
+	if !added[0].ConfirmBeforeUse {
+		t.Error("ConfirmBeforeUse not set")
+	}
+
+	keys, err := a.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ssh.FingerprintSHA256(signer.PublicKey())
+	if len(keys) != 1 || ssh.FingerprintSHA256(keys[0]) != want || keys[0].Comment != "test@host" {
+		t.Fatalf("keyring holds %v, want %s test@host", keys, want)
+	}
+}
+
+func TestAddKeyToAgentNoConstraints(t *testing.T) {
+	a, socket := serveAgent(t)
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := addKeyToAgent(priv, "", agentOptions{socket: socket}); err != nil {
+		t.Fatalf("addKeyToAgent: %v", err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.added) != 1 || a.added[0].LifetimeSecs != 0 || a.added[0].ConfirmBeforeUse {
+		t.Fatalf("unexpected constraints %+v", a.added)
+	}
+}
+
+func TestAddKeyToAgentRejectsNegativeLifetime(t *testing.T) {
+	_, socket := serveAgent(t)
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	if err := addKeyToAgent(priv, "", agentOptions{socket: socket, lifetime: -time.Second}); err == nil {
+		t.Fatal("negative lifetime accepted")
+	}
+}
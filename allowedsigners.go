@@ -0,0 +1,534 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : allowedsigners.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 14:05:33
+ * Description  : allowed_signers file parsing, principal matching and management commands
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// One entry of an allowed_signers file
+type allowedSigner struct {
+	lineNo        int
+	principals    []string // principal patterns
+	certAuthority bool
+	namespaces    []string // namespace patterns; empty allows any namespace
+	validAfter    time.Time
+	validBefore   time.Time
+	key           ssh.PublicKey
+	comment       string
+}
+
+// Problem found while parsing an allowed_signers file
+type allowedSignersError struct {
+	lineNo int
+	err    error
+}
+
+func (e allowedSignersError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.lineNo, e.err)
+}
+
+// parseAllowedSigners parses every entry, collecting one error per malformed line.
+func parseAllowedSigners(data []byte) ([]allowedSigner, []allowedSignersError) {
+	var signers []allowedSigner
+	var errs []allowedSignersError
+
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		s, err := parseAllowedSignerLine(line)
+		if err != nil {
+			errs = append(errs, allowedSignersError{lineNo: i + 1, err: err})
+			continue
+		}
+		s.lineNo = i + 1
+		signers = append(signers, s)
+	}
+	return signers, errs
+}
+
+// parseAllowedSignerLine parses "principals [options] keytype base64 [comment]".
+func parseAllowedSignerLine(line string) (allowedSigner, error) {
+	var s allowedSigner
+
+	var principals, rest string
+	if strings.HasPrefix(line, `"`) {
+		end := strings.Index(line[1:], `"`)
+		if end < 0 {
+			return s, errors.New("unterminated quoted principals")
+		}
+		principals, rest = line[1:end+1], line[end+2:]
+	} else {
+		i := strings.IndexAny(line, " \t")
+		if i < 0 {
+			return s, errors.New("missing key")
+		}
+		principals, rest = line[:i], line[i:]
+	}
+	if principals == "" {
+		return s, errors.New("empty principals")
+	}
+	s.principals = strings.Split(principals, ",")
+
+	key, comment, options, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(rest)))
+	if err != nil {
+		return s, fmt.Errorf("invalid key: %w", err)
+	}
+	s.key, s.comment = key, comment
+
+	for _, opt := range options {
+		name, value, hasValue := strings.Cut(opt, "=")
+		if hasValue {
+			value = unquoteOptionValue(value)
+		}
+		switch strings.ToLower(name) {
+		case "cert-authority":
+			s.certAuthority = true
+		case "namespaces":
+			if value == "" {
+				return s, errors.New("namespaces option needs a value")
+			}
+			s.namespaces = strings.Split(value, ",")
+		case "valid-after":
+			if s.validAfter, err = parseOpenSSHTime(value); err != nil {
+				return s, fmt.Errorf("valid-after: %w", err)
+			}
+		case "valid-before":
+			if s.validBefore, err = parseOpenSSHTime(value); err != nil {
+				return s, fmt.Errorf("valid-before: %w", err)
+			}
+		default:
+			return s, fmt.Errorf("unsupported option %q", name)
+		}
+	}
+	if !s.validAfter.IsZero() && !s.validBefore.IsZero() && !s.validBefore.After(s.validAfter) {
+		return s, errors.New("valid-before is not after valid-after")
+	}
+	return s, nil
+}
+
+// validAt reports whether the entry's validity interval includes t.
+func (s allowedSigner) validAt(t time.Time) bool {
+	if !s.validAfter.IsZero() && t.Before(s.validAfter) {
+		return false
+	}
+	if !s.validBefore.IsZero() && t.After(s.validBefore) {
+		return false
+	}
+	return true
+}
+
+// allowsNamespace reports whether the entry may sign in the namespace.
+func (s allowedSigner) allowsNamespace(namespace string) bool {
+	return len(s.namespaces) == 0 || matchPatternList(namespace, s.namespaces)
+}
+
+// matchesKey reports whether a signature key is accepted by the entry: the
+// same key for plain entries, or a certificate signed by the entry key and
+// valid for principal for cert-authority entries.
+func (s allowedSigner) matchesKey(key ssh.PublicKey, principal string, at time.Time) bool {
+	cert, isCert := key.(*ssh.Certificate)
+	if !s.certAuthority {
+		return !isCert && keysEqual(key, s.key)
+	}
+	if !isCert || cert.CertType != ssh.UserCert || !keysEqual(cert.SignatureKey, s.key) {
+		return false
+	}
+	checker := ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool { return keysEqual(auth, s.key) },
+		Clock:           func() time.Time { return at },
+	}
+	if principal == "" && len(cert.ValidPrincipals) > 0 {
+		// find-principals: only the CA signature and validity matter
+		principal = cert.ValidPrincipals[0]
+	}
+	return checker.CheckCert(principal, cert) == nil
+}
+
+// unquoteOptionValue removes surrounding quotes and \" escapes from an option value.
+func unquoteOptionValue(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		value = value[1 : len(value)-1]
+	}
+	return strings.ReplaceAll(value, `\"`, `"`)
+}
+
+// keysEqual compares two public keys by their wire encoding.
+func keysEqual(a, b ssh.PublicKey) bool {
+	return a != nil && b != nil && string(a.Marshal()) == string(b.Marshal())
+}
+
+// matchPatternList implements OpenSSH pattern-list matching: patterns may use
+// * and ?, and a match on a pattern prefixed with ! rejects the string.
+func matchPatternList(s string, patterns []string) bool {
+	matched := false
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		negated := strings.HasPrefix(p, "!")
+		if negated {
+			p = p[1:]
+		}
+		if wildcardMatch(p, s) {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// wildcardMatch matches s against a pattern containing * and ? wildcards.
+func wildcardMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 0 && pattern[0] == '*' {
+				pattern = pattern[1:]
+			}
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if wildcardMatch(pattern, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if s == "" {
+				return false
+			}
+		default:
+			if s == "" || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return s == ""
+}
+
+// String renders the entry as an allowed_signers line.
+func (s allowedSigner) String() string {
+	fields := []string{strings.Join(s.principals, ",")}
+
+	var opts []string
+	if s.certAuthority {
+		opts = append(opts, "cert-authority")
+	}
+	if len(s.namespaces) > 0 {
+		opts = append(opts, "namespaces="+quoteOptionValue(strings.Join(s.namespaces, ",")))
+	}
+	if !s.validAfter.IsZero() {
+		opts = append(opts, "valid-after="+quoteOptionValue(formatOpenSSHTime(s.validAfter)))
+	}
+	if !s.validBefore.IsZero() {
+		opts = append(opts, "valid-before="+quoteOptionValue(formatOpenSSHTime(s.validBefore)))
+	}
+	if len(opts) > 0 {
+		fields = append(fields, strings.Join(opts, ","))
+	}
+
+	fields = append(fields, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(s.key))))
+	if s.comment != "" {
+		fields = append(fields, s.comment)
+	}
+	return strings.Join(fields, " ")
+}
+
+// formatOpenSSHTime renders t as YYYYMMDDHHMMSS in UTC with the Z suffix.
+func formatOpenSSHTime(t time.Time) string {
+	return t.UTC().Format("20060102150405") + "Z"
+}
+
+// validatePrincipal rejects principals that cannot appear in an allowed_signers line.
+func validatePrincipal(p string) error {
+	if p == "" {
+		return errors.New("empty principal")
+	}
+	if strings.ContainsAny(p, " \t\r\n\",") {
+		return fmt.Errorf("invalid principal %q", p)
+	}
+	return nil
+}
+
+// Run the allowed-signers subcommand
+func runAllowedSigners(args []string) error {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s allowed-signers <add|remove|validate> [flags]\n", filepath.Base(os.Args[0]))
+	}
+	if len(args) == 0 {
+		usage()
+		return errors.New("missing allowed-signers action")
+	}
+
+	switch args[0] {
+	case "add":
+		return runAllowedSignersAdd(args[1:])
+	case "remove":
+		return runAllowedSignersRemove(args[1:])
+	case "validate":
+		return runAllowedSignersValidate(args[1:])
+	default:
+		usage()
+		return fmt.Errorf("unknown allowed-signers action %q", args[0])
+	}
+}
+
+// Run allowed-signers add
+func runAllowedSignersAdd(args []string) error {
+	fs := flag.NewFlagSet("allowed-signers add", flag.ExitOnError)
+	file := fs.String("f", "allowed_signers", "allowed_signers file")
+	principals := fs.String("I", "", "comma separated principals (e.g. user@example.com)")
+	namespaces := fs.String("namespaces", "", "comma separated namespaces the key may sign (e.g. git)")
+	validAfter := fs.String("valid-after", "", "key valid from this time (YYYYMMDD[HHMM[SS]][Z])")
+	validBefore := fs.String("valid-before", "", "key valid until this time (YYYYMMDD[HHMM[SS]][Z])")
+	certAuthority := fs.Bool("cert-authority", false, "trust certificates signed by this key instead of the key itself")
+	comment := fs.String("C", "", "comment (default: the comment of the public key file)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s allowed-signers add -I principals [flags] <key.pub>...\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *principals == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("-I and at least one public key file are required")
+	}
+
+	template := allowedSigner{certAuthority: *certAuthority}
+	for _, p := range strings.Split(*principals, ",") {
+		if err := validatePrincipal(p); err != nil {
+			return err
+		}
+		template.principals = append(template.principals, p)
+	}
+	if *namespaces != "" {
+		for _, ns := range strings.Split(*namespaces, ",") {
+			if ns == "" || strings.ContainsAny(ns, " \t\"") {
+				return fmt.Errorf("invalid namespace %q", ns)
+			}
+			template.namespaces = append(template.namespaces, ns)
+		}
+	}
+	var err error
+	if *validAfter != "" {
+		if template.validAfter, err = parseOpenSSHTime(*validAfter); err != nil {
+			return fmt.Errorf("valid-after: %w", err)
+		}
+	}
+	if *validBefore != "" {
+		if template.validBefore, err = parseOpenSSHTime(*validBefore); err != nil {
+			return fmt.Errorf("valid-before: %w", err)
+		}
+	}
+	if !template.validAfter.IsZero() && !template.validBefore.IsZero() && !template.validBefore.After(template.validAfter) {
+		return errors.New("valid-before must be after valid-after")
+	}
+
+	lines, perm, err := readTextLines(*file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		perm = 0o644
+	}
+
+	for _, path := range fs.Args() {
+		pub, pubComment, err := loadPublicKey(path)
+		if err != nil {
+			return err
+		}
+		if _, isCert := pub.(*ssh.Certificate); isCert {
+			return fmt.Errorf("%s: certificates cannot be listed directly; add the CA key with -cert-authority", path)
+		}
+		entry := template
+		entry.key = pub
+		entry.comment = pubComment
+		if *comment != "" {
+			entry.comment = *comment
+		}
+
+		replaced := false
+		for i, line := range lines {
+			existing, err := parseAllowedSignerLine(strings.TrimSpace(line))
+			if err != nil || !keysEqual(existing.key, pub) || existing.certAuthority != entry.certAuthority {
+				continue
+			}
+			lines[i] = entry.String()
+			replaced = true
+			fmt.Printf("%s: updated entry on line %d\n", path, i+1)
+			break
+		}
+		if !replaced {
+			lines = append(lines, entry.String())
+			fmt.Printf("%s: added %s for %s\n", path, ssh.FingerprintSHA256(pub), strings.Join(entry.principals, ","))
+		}
+	}
+	return writeTextLines(*file, lines, perm)
+}
+
+// Run allowed-signers remove
+func runAllowedSignersRemove(args []string) error {
+	fs := flag.NewFlagSet("allowed-signers remove", flag.ExitOnError)
+	file := fs.String("f", "allowed_signers", "allowed_signers file")
+	principal := fs.String("I", "", "remove entries listing this principal")
+	fingerprint := fs.String("fingerprint", "", "remove entries with this SHA256 key fingerprint")
+	fs.Parse(args)
+	if *principal == "" && *fingerprint == "" {
+		fs.Usage()
+		return errors.New("one of -I or -fingerprint is required")
+	}
+
+	lines, perm, err := readTextLines(*file)
+	if err != nil {
+		return err
+	}
+	kept := lines[:0]
+	removed := 0
+	for i, line := range lines {
+		entry, err := parseAllowedSignerLine(strings.TrimSpace(line))
+		if err == nil &&
+			(*fingerprint == "" || ssh.FingerprintSHA256(entry.key) == *fingerprint) &&
+			(*principal == "" || containsString(entry.principals, *principal)) {
+			fmt.Printf("removed line %d: %s %s\n", i+1, strings.Join(entry.principals, ","), ssh.FingerprintSHA256(entry.key))
+			removed++
+			continue
+		}
+		kept = append(kept, line)
+	}
+	if removed == 0 {
+		return errors.New("no matching entries found")
+	}
+	return writeTextLines(*file, kept, perm)
+}
+
+// Run allowed-signers validate
+func runAllowedSignersValidate(args []string) error {
+	fs := flag.NewFlagSet("allowed-signers validate", flag.ExitOnError)
+	file := fs.String("f", "allowed_signers", "allowed_signers file")
+	fs.Parse(args)
+
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	findings := validateAllowedSigners(data, time.Now())
+	if len(findings) == 0 {
+		fmt.Printf("%s: no problems found\n", *file)
+		return nil
+	}
+
+	errorsFound := 0
+	for _, f := range findings {
+		style := warningStyle
+		if f.Severity == severityError {
+			style = errorStyle
+			errorsFound++
+		}
+		fmt.Printf("%s:%d: %s %s\n", *file, f.Line, style.Render(f.Severity+":"), f.Message)
+	}
+	if errorsFound > 0 {
+		return fmt.Errorf("%d errors found", errorsFound)
+	}
+	return nil
+}
+
+// validateAllowedSigners reports syntax errors, expired and not yet valid
+// entries, and keys listed more than once.
+func validateAllowedSigners(data []byte, now time.Time) []lintFinding {
+	signers, errs := parseAllowedSigners(data)
+
+	var findings []lintFinding
+	for _, e := range errs {
+		findings = append(findings, lintFinding{Line: e.lineNo, Severity: severityError, Message: e.err.Error()})
+	}
+
+	seen := make(map[string]int)
+	for _, s := range signers {
+		if !s.validBefore.IsZero() && now.After(s.validBefore) {
+			findings = append(findings, lintFinding{Line: s.lineNo, Severity: severityWarning, Message: fmt.Sprintf("entry for %s expired on %s", strings.Join(s.principals, ","), s.validBefore.Format(time.RFC3339))})
+		}
+		if !s.validAfter.IsZero() && now.Before(s.validAfter) {
+			findings = append(findings, lintFinding{Line: s.lineNo, Severity: severityInfo, Message: fmt.Sprintf("entry for %s is not valid until %s", strings.Join(s.principals, ","), s.validAfter.Format(time.RFC3339))})
+		}
+		key := fmt.Sprintf("%t %s", s.certAuthority, ssh.FingerprintSHA256(s.key))
+		if first, dup := seen[key]; dup {
+			findings = append(findings, lintFinding{Line: s.lineNo, Severity: severityWarning, Message: fmt.Sprintf("key %s already listed on line %d", ssh.FingerprintSHA256(s.key), first)})
+		} else {
+			seen[key] = s.lineNo
+		}
+	}
+	sortFindings(findings)
+	return findings
+}
+
+// sortFindings orders findings by line number, keeping file-level findings first.
+func sortFindings(findings []lintFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+}
+
+// readTextLines reads a text file as lines, returning its permissions.
+func readTextLines(path string) ([]string, os.FileMode, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	text := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if text == "" {
+		return nil, info.Mode().Perm(), nil
+	}
+	return strings.Split(text, "\n"), info.Mode().Perm(), nil
+}
+
+// writeTextLines atomically replaces path with the given lines.
+func writeTextLines(path string, lines []string, perm os.FileMode) error {
+	data := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		data += "\n"
+	}
+	return writeFileAtomic(path, []byte(data), perm)
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
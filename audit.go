@@ -0,0 +1,473 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : audit.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 20:58:33
+ * Description  : Strength audit of existing RSA, ECDSA and DSA public keys
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Factorable keys are worse than errors
+const severityCritical = "critical"
+
+// Severities from worst to least bad
+var severityRank = map[string]int{severityCritical: 0, severityError: 1, severityWarning: 2, severityInfo: 3}
+
+// Audit checks
+const (
+	checkRSASize     = "rsa-size"
+	checkRSAExponent = "rsa-exponent"
+	checkFermat      = "fermat"
+	checkROCA        = "roca"
+	checkSharedPrime = "shared-prime"
+	checkECPoint     = "ecdsa-point"
+	checkDSA         = "dsa"
+)
+
+// Curves of OpenSSH ECDSA keys
+var ecdsaCurves = map[string]ecdh.Curve{
+	"nistp256": ecdh.P256(),
+	"nistp384": ecdh.P384(),
+	"nistp521": ecdh.P521(),
+}
+
+// Small primes of the ROCA test: Infineon RSALib primes are built so that
+// N mod p lies in the subgroup generated by 65537 for each of them.
+var rocaPrimes = []int64{3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37, 41, 43, 47, 53, 59, 61, 67,
+	71, 73, 79, 83, 89, 97, 101, 103, 107, 109, 113, 127, 131, 137, 139, 149, 151, 157, 163, 167}
+
+// One weakness of a key
+type auditFinding struct {
+	Severity    string   `json:"severity"`
+	Check       string   `json:"check"`
+	Algorithm   string   `json:"algorithm"`
+	Bits        int      `json:"bits,omitempty"`
+	Fingerprint string   `json:"fingerprint"`
+	Message     string   `json:"message"`
+	Locations   []string `json:"locations"`
+}
+
+// Public key fields decoded without the validation of the ssh package, so
+// that keys it rejects can still be audited
+type rawPublicKey struct {
+	algorithm string
+	e, n      *big.Int // RSA
+	curve     string   // ECDSA
+	point     []byte
+}
+
+// parseRawPublicKey decodes an SSH wire-format RSA, ECDSA or DSA public key.
+func parseRawPublicKey(blob []byte) (*rawPublicKey, error) {
+	var header struct {
+		Name string
+		Rest []byte `ssh:"rest"`
+	}
+	if err := ssh.Unmarshal(blob, &header); err != nil {
+		return nil, err
+	}
+	k := &rawPublicKey{algorithm: header.Name}
+	switch {
+	case header.Name == ssh.KeyAlgoRSA:
+		var w struct {
+			E, N *big.Int
+			Rest []byte `ssh:"rest"`
+		}
+		if err := ssh.Unmarshal(header.Rest, &w); err != nil {
+			return nil, err
+		}
+		k.e, k.n = w.E, w.N
+	case strings.HasPrefix(header.Name, "ecdsa-sha2-"):
+		var w struct {
+			Curve    string
+			KeyBytes []byte
+			Rest     []byte `ssh:"rest"`
+		}
+		if err := ssh.Unmarshal(header.Rest, &w); err != nil {
+			return nil, err
+		}
+		k.curve, k.point = w.Curve, w.KeyBytes
+	case header.Name == ssh.KeyAlgoDSA:
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", header.Name)
+	}
+	return k, nil
+}
+
+// bits returns the key size.
+func (k *rawPublicKey) bits() int {
+	switch {
+	case k.n != nil:
+		return k.n.BitLen()
+	case k.curve != "":
+		return map[string]int{"nistp256": 256, "nistp384": 384, "nistp521": 521}[k.curve]
+	}
+	return 0
+}
+
+// rawKeyItem keeps an authorized_keys style line the ssh package rejected,
+// such as an off-curve ECDSA point or a bad RSA exponent, for the audit.
+func rawKeyItem(base inventoryItem, line string) (inventoryItem, bool) {
+	fields := strings.Fields(line)
+	for i := 0; i+1 < len(fields); i++ {
+		if fields[i] != ssh.KeyAlgoRSA && !strings.HasPrefix(fields[i], "ecdsa-sha2-") {
+			continue
+		}
+		blob, err := base64.StdEncoding.DecodeString(fields[i+1])
+		if err != nil {
+			return base, false
+		}
+		k, err := parseRawPublicKey(blob)
+		if err != nil || k.algorithm != fields[i] {
+			return base, false
+		}
+		_, parseErr := ssh.ParsePublicKey(blob)
+		if parseErr == nil {
+			return base, false
+		}
+		item := base
+		item.Kind, item.Format = kindPublic, "ssh"
+		item.Algorithm, item.Bits = k.algorithm, k.bits()
+		item.Fingerprint = blobFingerprint(blob)
+		item.PublicKey = fields[i] + " " + fields[i+1]
+		item.Comment = strings.Join(fields[i+2:], " ")
+		item.Problems = append(item.Problems, "rejected by the SSH parser: "+strings.TrimPrefix(parseErr.Error(), "ssh: "))
+		return item, true
+	}
+	return base, false
+}
+
+// blobFingerprint is ssh.FingerprintSHA256 for keys the ssh package rejects.
+func blobFingerprint(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// fermatFactor looks for p and q close to sqrt(n), trying at most rounds
+// values of a in a^2 - n = b^2. It returns p, or nil.
+func fermatFactor(n *big.Int, rounds int) *big.Int {
+	if n.Sign() <= 0 || n.Bit(0) == 0 {
+		return nil
+	}
+	a := new(big.Int).Sqrt(n)
+	if new(big.Int).Mul(a, a).Cmp(n) < 0 {
+		a.Add(a, big.NewInt(1))
+	}
+	b2 := new(big.Int).Mul(a, a)
+	b2.Sub(b2, n)
+	b, check := new(big.Int), new(big.Int)
+	for i := 0; i < rounds; i++ {
+		b.Sqrt(b2)
+		if check.Mul(b, b).Cmp(b2) == 0 {
+			return new(big.Int).Sub(a, b)
+		}
+		// (a+1)^2 - n = b2 + 2a + 1
+		b2.Add(b2, a).Add(b2, a).Add(b2, big.NewInt(1))
+		a.Add(a, big.NewInt(1))
+	}
+	return nil
+}
+
+// rocaFingerprint reports whether n has the structure of moduli generated by
+// the vulnerable Infineon RSALib (CVE-2017-15361).
+func rocaFingerprint(n *big.Int) bool {
+	for _, p := range rocaPrimes {
+		r := new(big.Int).Mod(n, big.NewInt(p)).Int64()
+		g, x, found := 65537%p, int64(1), false
+		for {
+			if x == r {
+				found = true
+				break
+			}
+			if x = x * g % p; x == 1 {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// batchGCD returns gcd(n_i, product of all other moduli) for every modulus
+// using product and remainder trees (Bernstein, as in Heninger et al.).
+// The moduli must be distinct.
+func batchGCD(moduli []*big.Int) []*big.Int {
+	tree := [][]*big.Int{moduli}
+	for len(tree[len(tree)-1]) > 1 {
+		prev := tree[len(tree)-1]
+		next := make([]*big.Int, (len(prev)+1)/2)
+		for i := range next {
+			if 2*i+1 < len(prev) {
+				next[i] = new(big.Int).Mul(prev[2*i], prev[2*i+1])
+			} else {
+				next[i] = prev[2*i]
+			}
+		}
+		tree = append(tree, next)
+	}
+
+	rems := tree[len(tree)-1]
+	for level := len(tree) - 2; level >= 0; level-- {
+		nodes := tree[level]
+		next := make([]*big.Int, len(nodes))
+		for i, n := range nodes {
+			square := new(big.Int).Mul(n, n)
+			next[i] = new(big.Int).Mod(rems[i/2], square)
+		}
+		rems = next
+	}
+
+	gcds := make([]*big.Int, len(moduli))
+	for i, n := range moduli {
+		q := new(big.Int).Quo(rems[i], n)
+		gcds[i] = new(big.Int).GCD(nil, nil, q, n)
+	}
+	return gcds
+}
+
+// Audit settings
+type auditOptions struct {
+	minRSABits   int
+	fermatRounds int
+}
+
+// auditKeys checks every distinct public key once, then looks for RSA
+// moduli sharing a prime across the whole set.
+func auditKeys(items []inventoryItem, opts auditOptions) []auditFinding {
+	type auditedKey struct {
+		key         *rawPublicKey
+		fingerprint string
+		locations   []string
+	}
+	var keys []*auditedKey
+	byFingerprint := map[string]*auditedKey{}
+	for _, it := range items {
+		fields := strings.Fields(it.PublicKey)
+		if len(fields) < 2 {
+			continue
+		}
+		where := it.Host + ":" + it.Path
+		if ak, ok := byFingerprint[it.Fingerprint]; ok {
+			if !containsString(ak.locations, where) {
+				ak.locations = append(ak.locations, where)
+			}
+			continue
+		}
+		blob, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			continue
+		}
+		k, err := parseRawPublicKey(blob)
+		if err != nil {
+			continue
+		}
+		ak := &auditedKey{key: k, fingerprint: blobFingerprint(blob), locations: []string{where}}
+		byFingerprint[it.Fingerprint] = ak
+		keys = append(keys, ak)
+	}
+
+	var findings []auditFinding
+	add := func(ak *auditedKey, severity, check, format string, args ...interface{}) {
+		findings = append(findings, auditFinding{
+			Severity:    severity,
+			Check:       check,
+			Algorithm:   ak.key.algorithm,
+			Bits:        ak.key.bits(),
+			Fingerprint: ak.fingerprint,
+			Message:     fmt.Sprintf(format, args...),
+			Locations:   ak.locations,
+		})
+	}
+
+	var moduli []*big.Int
+	var moduliKeys []*auditedKey
+	seenModuli := map[string]bool{}
+	for _, ak := range keys {
+		k := ak.key
+		switch {
+		case k.n != nil:
+			bits := k.n.BitLen()
+			switch {
+			case bits < 1024:
+				add(ak, severityCritical, checkRSASize, "%d-bit modulus can be factored", bits)
+			case bits < 2048:
+				add(ak, severityError, checkRSASize, "%d-bit modulus is below the 2048-bit minimum", bits)
+			case bits < opts.minRSABits:
+				add(ak, severityWarning, checkRSASize, "%d-bit modulus is below the policy of %d bits", bits, opts.minRSABits)
+			}
+
+			switch e := k.e; {
+			case e.Cmp(big.NewInt(3)) < 0 || e.Bit(0) == 0:
+				add(ak, severityCritical, checkRSAExponent, "invalid public exponent %s", e)
+			case e.Cmp(big.NewInt(65537)) < 0:
+				add(ak, severityError, checkRSAExponent, "small public exponent %s", e)
+			case e.Cmp(big.NewInt(65537)) > 0:
+				add(ak, severityWarning, checkRSAExponent, "unusual public exponent %s", e)
+			}
+
+			if p := fermatFactor(k.n, opts.fermatRounds); p != nil {
+				add(ak, severityCritical, checkFermat, "p and q are close; factored by Fermat's method (|p-q| is %d bits)",
+					new(big.Int).Sub(new(big.Int).Quo(k.n, p), p).BitLen())
+			}
+			if rocaFingerprint(k.n) {
+				add(ak, severityCritical, checkROCA, "modulus has the ROCA fingerprint (Infineon RSALib, CVE-2017-15361)")
+			}
+			if k.n.Cmp(big.NewInt(1)) > 0 && !seenModuli[k.n.String()] {
+				seenModuli[k.n.String()] = true
+				moduli = append(moduli, k.n)
+				moduliKeys = append(moduliKeys, ak)
+			}
+
+		case k.curve != "":
+			curve, ok := ecdsaCurves[k.curve]
+			switch {
+			case !ok:
+				add(ak, severityWarning, checkECPoint, "unknown curve %q", k.curve)
+			case "ecdsa-sha2-"+k.curve != k.algorithm:
+				add(ak, severityError, checkECPoint, "curve %s does not match key type %s", k.curve, k.algorithm)
+			default:
+				if _, err := curve.NewPublicKey(k.point); err != nil {
+					add(ak, severityCritical, checkECPoint, "public point is not on the %s curve", k.curve)
+				}
+			}
+
+		case k.algorithm == ssh.KeyAlgoDSA:
+			add(ak, severityError, checkDSA, "DSA keys are limited to 1024 bits and deprecated by OpenSSH")
+		}
+	}
+
+	// Moduli that share a prime with another modulus in the set
+	if len(moduli) > 1 {
+		gcds := batchGCD(moduli)
+		var weak []int
+		for i, g := range gcds {
+			if g.Cmp(big.NewInt(1)) > 0 {
+				weak = append(weak, i)
+			}
+		}
+		for _, i := range weak {
+			var partners []string
+			for _, j := range weak {
+				if i != j && new(big.Int).GCD(nil, nil, moduli[i], moduli[j]).Cmp(big.NewInt(1)) > 0 {
+					partners = append(partners, moduliKeys[j].fingerprint)
+				}
+			}
+			add(moduliKeys[i], severityCritical, checkSharedPrime, "shares a prime factor with %s", strings.Join(partners, ", "))
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityRank[findings[i].Severity] < severityRank[findings[j].Severity]
+	})
+	return findings
+}
+
+// Run the audit subcommand
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text or json")
+	minRSA := fs.Int("min-rsa", 3072, "smallest acceptable RSA key size")
+	fermatRounds := fs.Int("fermat-rounds", 10000, "Fermat factoring iterations per RSA key")
+	host := fs.String("host", "", "host name recorded for scanned paths (default: this host)")
+	quiet := fs.Bool("q", false, "do not print unreadable paths")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s audit [flags] <inventory.json|dir|file>... (default: ~/.ssh)\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *fermatRounds < 0 {
+		return errors.New("-fermat-rounds must not be negative")
+	}
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		inputs = []string{filepath.Join(home, ".ssh")}
+	}
+	if *host == "" {
+		*host, _ = os.Hostname()
+	}
+	warn := func(err error) {
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
+	items, err := loadInventories(inputs, *host, scanPolicy{}, warn)
+	if err != nil {
+		return err
+	}
+	findings := auditKeys(items, auditOptions{minRSABits: *minRSA, fermatRounds: *fermatRounds})
+
+	switch *format {
+	case "json":
+		data, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "text":
+		printAuditFindings(findings)
+	default:
+		return fmt.Errorf("unknown format %q (text or json)", *format)
+	}
+
+	errorsFound := 0
+	for _, f := range findings {
+		if severityRank[f.Severity] <= severityRank[severityError] {
+			errorsFound++
+		}
+	}
+	if errorsFound > 0 {
+		return fmt.Errorf("%d critical or error findings", errorsFound)
+	}
+	return nil
+}
+
+// printAuditFindings prints each finding followed by the files holding the key.
+func printAuditFindings(findings []auditFinding) {
+	if len(findings) == 0 {
+		fmt.Println("No weak keys found")
+		return
+	}
+	for _, f := range findings {
+		style := warningStyle
+		if severityRank[f.Severity] <= severityRank[severityError] {
+			style = errorStyle
+		}
+		fmt.Printf("%s [%s] %s %d %s: %s\n", style.Render(f.Severity+":"), f.Check, f.Algorithm, f.Bits, f.Fingerprint, f.Message)
+		for _, loc := range f.Locations {
+			fmt.Printf("    %s\n", loc)
+		}
+	}
+}
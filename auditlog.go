@@ -0,0 +1,426 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : auditlog.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 23:31:12
+ * Description  : Hash-chained JSON-lines audit log of key file changes
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Audit log events
+const (
+	auditGenerated   = "generated"
+	auditOverwritten = "overwritten"
+	auditConverted   = "converted"
+	auditDeleted     = "deleted"
+	auditRolledBack  = "rolled_back" // a failed hook restored the previous files
+)
+
+// prev_hash of the first record in a log
+var auditGenesisHash = strings.Repeat("0", 64)
+
+// Marker between a record and its hash. The hash covers the record bytes
+// before it, closed with '}'.
+const auditHashField = `,"hash":"`
+
+// Default syslog tag
+const defaultSyslogTag = "abdal-4iproto-keygen"
+
+// Serialises appends from concurrent batch and multi-key workers; other
+// processes are kept out with a file lock.
+var auditMu sync.Mutex
+
+// Connection to the local syslog daemon
+type syslogWriter interface {
+	Notice(message string) error
+	Close() error
+}
+
+// Where audit records are sent
+type auditLogConfig struct {
+	Path      string `yaml:"path" json:"path"`             // JSON-lines file
+	Syslog    bool   `yaml:"syslog" json:"syslog"`         // also send records to the local syslog
+	SyslogTag string `yaml:"syslog_tag" json:"syslog_tag"` // default abdal-4iproto-keygen
+}
+
+// One audit log entry. It describes key files, never their private contents.
+type auditRecord struct {
+	Seq         int64     `json:"seq"`
+	Time        time.Time `json:"time"`
+	Event       string    `json:"event"`
+	User        string    `json:"user"`
+	UID         string    `json:"uid,omitempty"`
+	SudoUser    string    `json:"sudo_user,omitempty"`
+	Host        string    `json:"host"`
+	Command     string    `json:"command"`
+	Algorithm   string    `json:"algorithm,omitempty"`
+	Bits        int       `json:"bits,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Paths       []string  `json:"paths"`
+	Source      string    `json:"source,omitempty"` // input file of a conversion
+	PrevHash    string    `json:"prev_hash"`
+	Hash        string    `json:"hash,omitempty"` // only set when read back
+}
+
+// enabled reports whether audit records are written anywhere.
+func (c auditLogConfig) enabled() bool {
+	return c.Path != "" || c.Syslog
+}
+
+// newAuditRecord describes an event on the key files at paths. pub may be nil
+// when the key is not known.
+func newAuditRecord(event string, pub ssh.PublicKey, paths ...string) auditRecord {
+	rec := auditRecord{
+		Time:     time.Now().UTC().Truncate(time.Millisecond),
+		Event:    event,
+		SudoUser: os.Getenv("SUDO_USER"),
+		Command:  auditCommand(),
+		Paths:    []string{},
+	}
+	if u, err := user.Current(); err == nil {
+		rec.User, rec.UID = u.Username, u.Uid
+	}
+	rec.Host, _ = os.Hostname()
+	if pub != nil {
+		rec.Algorithm = pub.Type()
+		rec.Bits = publicKeyBits(pub)
+		rec.Fingerprint = ssh.FingerprintSHA256(pub)
+	}
+	for _, p := range paths {
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		rec.Paths = append(rec.Paths, p)
+	}
+	return rec
+}
+
+// auditCommand names the running mode: the subcommand and its action, the
+// interactive mode, or generate.
+func auditCommand() string {
+	if len(os.Args) < 2 {
+		return "interactive"
+	}
+	cmd := findCommand(os.Args[1])
+	if cmd == nil {
+		return "generate"
+	}
+	switch cmd.Name {
+	case "rotate", "ppk", "jwk", "authorized-keys", "allowed-signers":
+		if len(os.Args) > 2 && !strings.HasPrefix(os.Args[2], "-") {
+			return cmd.Name + " " + os.Args[2]
+		}
+	}
+	return cmd.Name
+}
+
+// writtenEvent returns overwritten when any of the files existed before,
+// generated otherwise.
+func writtenEvent(backups ...keyFileBackup) string {
+	for _, b := range backups {
+		if b.exists {
+			return auditOverwritten
+		}
+	}
+	return auditGenerated
+}
+
+// auditLogFor returns the audit log settings of cfg, or of the default
+// configuration file when cfg is nil.
+func auditLogFor(cfg *appConfig) (auditLogConfig, error) {
+	if cfg == nil {
+		var err error
+		if cfg, err = loadConfig(""); err != nil {
+			return auditLogConfig{}, err
+		}
+	}
+	return cfg.AuditLog, nil
+}
+
+// logKeyPairWritten records a freshly written key pair as generated or
+// overwritten. When the record cannot be written the previous files are
+// restored, so no key is left without a record.
+func logKeyPairWritten(cfg *appConfig, privatePath, publicPath string, backups ...keyFileBackup) error {
+	c, err := auditLogFor(cfg)
+	if err == nil && !c.enabled() {
+		return nil
+	}
+	var pub ssh.PublicKey
+	if err == nil {
+		pub, _, err = loadPublicKey(publicPath)
+	}
+	if err == nil {
+		err = logKeyEvent(cfg, newAuditRecord(writtenEvent(backups...), pub, privatePath, publicPath))
+	}
+	if err != nil {
+		return rollbackKeyFiles(err, backups...)
+	}
+	return nil
+}
+
+// logConversion records key files written from the key in source in the audit
+// log of cfg. key is the public or private key that was converted.
+func logConversion(cfg *appConfig, source string, key interface{}, paths ...string) error {
+	if signer, ok := key.(crypto.Signer); ok {
+		key = signer.Public()
+	}
+	var pub ssh.PublicKey
+	if key != nil {
+		// Unsupported key types are recorded without algorithm and fingerprint
+		pub, _ = ssh.NewPublicKey(key)
+	}
+	rec := newAuditRecord(auditConverted, pub, paths...)
+	if abs, err := filepath.Abs(source); err == nil {
+		source = abs
+	}
+	rec.Source = source
+	return logKeyEvent(cfg, rec)
+}
+
+// logKeyEvent records an event in the audit log configured in cfg, or in the
+// default configuration file when cfg is nil. Nothing is written when no
+// audit log is configured.
+func logKeyEvent(cfg *appConfig, rec auditRecord) error {
+	c, err := auditLogFor(cfg)
+	if err != nil {
+		return err
+	}
+	if !c.enabled() {
+		return nil
+	}
+
+	// Connect to syslog first so a missing daemon does not leave a record
+	// in the file for a change that is then rolled back
+	var w syslogWriter
+	if c.Syslog {
+		tag := c.SyslogTag
+		if tag == "" {
+			tag = defaultSyslogTag
+		}
+		if w, err = dialSyslog(tag); err != nil {
+			return fmt.Errorf("audit log: syslog: %w", err)
+		}
+		defer w.Close()
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	var line []byte
+	if c.Path != "" {
+		if line, err = appendAuditRecord(c.Path, rec); err != nil {
+			return fmt.Errorf("audit log: %w", err)
+		}
+	} else {
+		// Syslog only: no chain to continue
+		rec.PrevHash = auditGenesisHash
+		line = sealAuditRecord(rec)
+	}
+	if w != nil {
+		if err := w.Notice(string(line)); err != nil {
+			return fmt.Errorf("audit log: syslog: %w", err)
+		}
+	}
+	return nil
+}
+
+// appendAuditRecord chains rec to the last record of the log at path and
+// appends it, returning the written line without its newline.
+func appendAuditRecord(path string, rec auditRecord) ([]byte, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return nil, err
+	}
+	defer unlockFile(f)
+
+	last, err := lastLine(f)
+	if err != nil {
+		return nil, err
+	}
+	rec.Seq, rec.PrevHash = 1, auditGenesisHash
+	if len(last) > 0 {
+		var prev auditRecord
+		if err := json.Unmarshal(last, &prev); err != nil || prev.Hash == "" {
+			return nil, fmt.Errorf("%s: last record is damaged (run verify-log)", path)
+		}
+		rec.Seq, rec.PrevHash = prev.Seq+1, prev.Hash
+	}
+
+	line := sealAuditRecord(rec)
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+	return line, f.Sync()
+}
+
+// sealAuditRecord marshals rec and appends the SHA-256 of those bytes as its
+// hash field.
+func sealAuditRecord(rec auditRecord) []byte {
+	rec.Hash = ""
+	data, _ := json.Marshal(rec)
+	sum := sha256.Sum256(data)
+	line := append(data[:len(data)-1:len(data)-1], auditHashField...)
+	line = append(line, hex.EncodeToString(sum[:])...)
+	return append(line, `"}`...)
+}
+
+// lastLine returns the last non-empty line of f.
+func lastLine(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	const chunk = 64 << 10
+	size, offset := info.Size(), int64(0)
+	if size > chunk {
+		offset = size - chunk
+	}
+	buf := make([]byte, size-offset)
+	if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = bytes.TrimRight(buf, "\r\n")
+	i := bytes.LastIndexByte(buf, '\n')
+	if i < 0 && offset > 0 {
+		return nil, errors.New("last record is too long")
+	}
+	return buf[i+1:], nil
+}
+
+// Problem found while verifying a log
+type auditLogProblem struct {
+	Line    int
+	Message string
+}
+
+// verifyAuditLog checks every record's hash, its link to the previous record
+// and the sequence numbers. It returns the number of records and the hash of
+// the last one.
+func verifyAuditLog(r io.Reader) (int, string, []auditLogProblem) {
+	var problems []auditLogProblem
+	prevHash, prevSeq, count := auditGenesisHash, int64(0), 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := bytes.TrimRight(scanner.Bytes(), "\r")
+		if len(line) == 0 {
+			continue
+		}
+		count++
+		report := func(format string, args ...interface{}) {
+			problems = append(problems, auditLogProblem{Line: lineNo, Message: fmt.Sprintf(format, args...)})
+		}
+
+		var rec auditRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			report("not a valid record: %v", err)
+			prevSeq++
+			continue
+		}
+		i := bytes.LastIndex(line, []byte(auditHashField))
+		if i < 0 || rec.Hash == "" || !bytes.HasSuffix(line, []byte(`"}`)) {
+			report("record has no hash")
+		} else {
+			sum := sha256.Sum256(append(line[:i:i], '}'))
+			if hex.EncodeToString(sum[:]) != rec.Hash {
+				report("hash mismatch: record was modified")
+			}
+		}
+		if rec.PrevHash != prevHash {
+			report("prev_hash does not match the previous record: records were removed, inserted or reordered")
+		}
+		if rec.Seq != prevSeq+1 {
+			report("sequence %d follows %d", rec.Seq, prevSeq)
+		}
+		prevHash, prevSeq = rec.Hash, rec.Seq
+	}
+	if err := scanner.Err(); err != nil {
+		problems = append(problems, auditLogProblem{Line: lineNo + 1, Message: err.Error()})
+	}
+	return count, prevHash, problems
+}
+
+// Run the verify-log subcommand
+func runVerifyLog(args []string) error {
+	fs := flag.NewFlagSet("verify-log", flag.ExitOnError)
+	head := fs.String("head", "", "expected hash of the last record, recorded earlier, to detect a truncated log")
+	configPath := fs.String("config", "", "configuration file naming the audit log when no file is given")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s verify-log [flags] [audit.jsonl]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errors.New("at most one log file is allowed")
+	}
+
+	path := fs.Arg(0)
+	if path == "" {
+		config, err := loadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		if path = config.AuditLog.Path; path == "" {
+			fs.Usage()
+			return errors.New("no log file given and no audit_log path configured")
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	count, last, problems := verifyAuditLog(f)
+	for _, p := range problems {
+		fmt.Printf("%s %s:%d: %s\n", errorStyle.Render("error:"), path, p.Line, p.Message)
+	}
+	if *head != "" && last != *head {
+		fmt.Printf("%s %s: last record hash is %s, expected %s: records were removed from the end\n", errorStyle.Render("error:"), path, last, *head)
+		problems = append(problems, auditLogProblem{})
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems found in %s", len(problems), path)
+	}
+	fmt.Printf("%s %d records verified\n", successStyle.Render("ok:"), count)
+	fmt.Printf("head: %s\n", last)
+	return nil
+}
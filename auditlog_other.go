@@ -0,0 +1,41 @@
+//go:build !unix
+
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : auditlog_other.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 23:33:58
+ * Description  : Audit log on systems without flock and syslog
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// lockFile does nothing: appends from a single process are serialised by
+// auditMu.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile does nothing.
+func unlockFile(f *os.File) error {
+	return nil
+}
+
+// dialSyslog fails: there is no local syslog to send to.
+func dialSyslog(tag string) (syslogWriter, error) {
+	return nil, errors.New("syslog is not supported on this system")
+}
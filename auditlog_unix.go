@@ -0,0 +1,42 @@
+//go:build unix
+
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : auditlog_unix.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 23:33:40
+ * Description  : Audit log file locking and syslog on Unix systems
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"log/syslog"
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on f, waiting for other writers.
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+// unlockFile releases the lock taken by lockFile.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// dialSyslog connects to the local syslog daemon, logging under the authpriv
+// facility.
+func dialSyslog(tag string) (syslogWriter, error) {
+	return syslog.New(syslog.LOG_AUTHPRIV|syslog.LOG_NOTICE, tag)
+}
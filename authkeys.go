@@ -0,0 +1,437 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : authkeys.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 13:10:46
+ * Description  : authorized_keys management commands (add, remove, list, lint)
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// One line of an authorized_keys file. Blank and comment lines keep only raw.
+type authorizedKeysLine struct {
+	lineNo  int
+	raw     string
+	key     ssh.PublicKey
+	comment string
+	options []string
+	err     error
+}
+
+// Lint finding severities
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+// Lint finding for an authorized_keys file
+type lintFinding struct {
+	Line     int    `json:"line,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// Run the authorized-keys subcommand
+func runAuthorizedKeys(args []string) error {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s authorized-keys <add|remove|list|lint> [flags]\n", filepath.Base(os.Args[0]))
+	}
+	if len(args) == 0 {
+		usage()
+		return errors.New("missing authorized-keys action")
+	}
+
+	switch args[0] {
+	case "add":
+		return runAuthorizedKeysAdd(args[1:])
+	case "remove":
+		return runAuthorizedKeysRemove(args[1:])
+	case "list":
+		return runAuthorizedKeysList(args[1:])
+	case "lint":
+		return runAuthorizedKeysLint(args[1:])
+	default:
+		usage()
+		return fmt.Errorf("unknown authorized-keys action %q", args[0])
+	}
+}
+
+// Run authorized-keys add
+func runAuthorizedKeysAdd(args []string) error {
+	fs := flag.NewFlagSet("authorized-keys add", flag.ExitOnError)
+	file := fs.String("f", defaultAuthorizedKeysPath(), "authorized_keys file")
+	replace := fs.Bool("replace", false, "replace the options and comment of a key that is already present")
+	var opts authorizedKeyOptions
+	fs.Func("O", "authorized_keys option for the added keys (repeatable, see -O of key generation)", opts.set)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s authorized-keys add [flags] <key.pub>...\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("at least one public key file is required")
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	lines, perm, err := readAuthorizedKeysFile(*file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	changed := false
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		pub, comment, keyOpts, _, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		entry := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+		if comment != "" {
+			entry += " " + comment
+		}
+		if !opts.isEmpty() {
+			entry = opts.String() + " " + entry
+		} else if len(keyOpts) > 0 {
+			entry = strings.Join(keyOpts, ",") + " " + entry
+		}
+
+		fingerprint := ssh.FingerprintSHA256(pub)
+		if i := findAuthorizedKey(lines, fingerprint); i >= 0 {
+			if !*replace {
+				fmt.Printf("%s: %s already present on line %d\n", path, fingerprint, lines[i].lineNo)
+				continue
+			}
+			lines[i].raw = entry
+			fmt.Printf("%s: replaced %s on line %d\n", path, fingerprint, lines[i].lineNo)
+		} else {
+			lines = append(lines, authorizedKeysLine{lineNo: len(lines) + 1, raw: entry, key: pub, comment: comment})
+			fmt.Printf("%s: added %s\n", path, fingerprint)
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(*file), 0o700); err != nil {
+		return err
+	}
+	return writeAuthorizedKeysFile(*file, lines, perm)
+}
+
+// Run authorized-keys remove
+func runAuthorizedKeysRemove(args []string) error {
+	fs := flag.NewFlagSet("authorized-keys remove", flag.ExitOnError)
+	file := fs.String("f", defaultAuthorizedKeysPath(), "authorized_keys file")
+	fingerprint := fs.String("fingerprint", "", "remove entries with this SHA256 fingerprint")
+	comment := fs.String("comment", "", "remove entries with exactly this comment")
+	fs.Parse(args)
+
+	if *fingerprint == "" && *comment == "" {
+		fs.Usage()
+		return errors.New("one of -fingerprint or -comment is required")
+	}
+
+	lines, perm, err := readAuthorizedKeysFile(*file)
+	if err != nil {
+		return err
+	}
+
+	kept := lines[:0]
+	removed := 0
+	for _, l := range lines {
+		if l.key != nil &&
+			(*fingerprint == "" || ssh.FingerprintSHA256(l.key) == *fingerprint) &&
+			(*comment == "" || l.comment == *comment) {
+			fmt.Printf("removed line %d: %s %s\n", l.lineNo, ssh.FingerprintSHA256(l.key), l.comment)
+			removed++
+			continue
+		}
+		kept = append(kept, l)
+	}
+
+	if removed == 0 {
+		return errors.New("no matching entries found")
+	}
+	return writeAuthorizedKeysFile(*file, kept, perm)
+}
+
+// Run authorized-keys list
+func runAuthorizedKeysList(args []string) error {
+	fs := flag.NewFlagSet("authorized-keys list", flag.ExitOnError)
+	file := fs.String("f", defaultAuthorizedKeysPath(), "authorized_keys file")
+	asJSON := fs.Bool("json", false, "print entries as JSON")
+	fs.Parse(args)
+
+	lines, _, err := readAuthorizedKeysFile(*file)
+	if err != nil {
+		return err
+	}
+
+	type listEntry struct {
+		Line        int      `json:"line"`
+		Type        string   `json:"type"`
+		Bits        int      `json:"bits"`
+		Fingerprint string   `json:"fingerprint"`
+		Comment     string   `json:"comment"`
+		Options     []string `json:"options,omitempty"`
+	}
+	var entries []listEntry
+	for _, l := range lines {
+		if l.key == nil {
+			continue
+		}
+		entries = append(entries, listEntry{
+			Line:        l.lineNo,
+			Type:        l.key.Type(),
+			Bits:        publicKeyBits(l.key),
+			Fingerprint: ssh.FingerprintSHA256(l.key),
+			Comment:     l.comment,
+			Options:     l.options,
+		})
+	}
+
+	if *asJSON {
+		out, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	for _, e := range entries {
+		fmt.Printf("%4d  %-20s %5d  %s  %s\n", e.Line, e.Type, e.Bits, e.Fingerprint, e.Comment)
+		if len(e.Options) > 0 {
+			fmt.Printf("      options: %s\n", strings.Join(e.Options, ","))
+		}
+	}
+	return nil
+}
+
+// Run authorized-keys lint
+func runAuthorizedKeysLint(args []string) error {
+	fs := flag.NewFlagSet("authorized-keys lint", flag.ExitOnError)
+	file := fs.String("f", defaultAuthorizedKeysPath(), "authorized_keys file")
+	minRSA := fs.Int("min-rsa", 3072, "minimum acceptable RSA key size")
+	fs.Parse(args)
+
+	findings, err := lintAuthorizedKeysFile(*file, *minRSA)
+	if err != nil {
+		return err
+	}
+	if len(findings) == 0 {
+		fmt.Printf("%s: no problems found\n", *file)
+		return nil
+	}
+
+	errorsFound := 0
+	for _, f := range findings {
+		style := warningStyle
+		if f.Severity == severityError {
+			style = errorStyle
+			errorsFound++
+		}
+		location := *file
+		if f.Line > 0 {
+			location = fmt.Sprintf("%s:%d", *file, f.Line)
+		}
+		fmt.Printf("%s: %s %s\n", location, style.Render(f.Severity+":"), f.Message)
+	}
+	if errorsFound > 0 {
+		return fmt.Errorf("%d errors found", errorsFound)
+	}
+	return nil
+}
+
+// lintAuthorizedKeysFile checks an authorized_keys file for weak algorithms,
+// duplicate keys, malformed lines, missing comments and unsafe permissions.
+func lintAuthorizedKeysFile(path string, minRSA int) ([]lintFinding, error) {
+	lines, _, err := readAuthorizedKeysFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []lintFinding
+	findings = append(findings, lintAuthorizedKeysPermissions(path)...)
+
+	seen := make(map[string]int)
+	for _, l := range lines {
+		if l.err != nil {
+			findings = append(findings, lintFinding{Line: l.lineNo, Severity: severityError, Message: "malformed entry: " + l.err.Error()})
+			continue
+		}
+		if l.key == nil {
+			continue
+		}
+
+		fingerprint := ssh.FingerprintSHA256(l.key)
+		if first, dup := seen[fingerprint]; dup {
+			findings = append(findings, lintFinding{Line: l.lineNo, Severity: severityWarning, Message: fmt.Sprintf("duplicate key %s (first on line %d)", fingerprint, first)})
+		} else {
+			seen[fingerprint] = l.lineNo
+		}
+
+		switch l.key.Type() {
+		case ssh.KeyAlgoDSA:
+			findings = append(findings, lintFinding{Line: l.lineNo, Severity: severityError, Message: "DSA keys are weak and disabled by modern OpenSSH"})
+		case ssh.KeyAlgoRSA:
+			if bits := publicKeyBits(l.key); bits < 2048 {
+				findings = append(findings, lintFinding{Line: l.lineNo, Severity: severityError, Message: fmt.Sprintf("RSA key is only %d bits", bits)})
+			} else if bits < minRSA {
+				findings = append(findings, lintFinding{Line: l.lineNo, Severity: severityWarning, Message: fmt.Sprintf("RSA key is %d bits (policy minimum %d)", bits, minRSA)})
+			}
+		}
+
+		if l.comment == "" {
+			findings = append(findings, lintFinding{Line: l.lineNo, Severity: severityWarning, Message: "entry has no comment identifying its owner"})
+		}
+	}
+	return findings, nil
+}
+
+// lintAuthorizedKeysPermissions flags modes that sshd's StrictModes rejects.
+func lintAuthorizedKeysPermissions(path string) []lintFinding {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	var findings []lintFinding
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if mode := info.Mode().Perm(); mode&0o022 != 0 {
+		findings = append(findings, lintFinding{Severity: severityError, Message: fmt.Sprintf("file mode %04o is group or world writable", mode)})
+	} else if mode&0o004 != 0 {
+		findings = append(findings, lintFinding{Severity: severityInfo, Message: fmt.Sprintf("file mode %04o is world readable (0600 recommended)", mode)})
+	}
+
+	dir := filepath.Dir(path)
+	if info, err := os.Stat(dir); err == nil {
+		if mode := info.Mode().Perm(); mode&0o022 != 0 {
+			findings = append(findings, lintFinding{Severity: severityError, Message: fmt.Sprintf("directory %s mode %04o is group or world writable", dir, mode)})
+		}
+	}
+	return findings
+}
+
+// readAuthorizedKeysFile parses an authorized_keys file line by line and
+// returns its permissions so edits can preserve them.
+func readAuthorizedKeysFile(path string) ([]authorizedKeysLine, os.FileMode, error) {
+	perm := os.FileMode(0o600)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, perm, err
+	}
+	perm = info.Mode().Perm()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, perm, err
+	}
+	return parseAuthorizedKeysLines(data), perm, nil
+}
+
+// parseAuthorizedKeysLines splits data into lines, parsing the key entries.
+func parseAuthorizedKeysLines(data []byte) []authorizedKeysLine {
+	text := strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	if text == "" {
+		return nil
+	}
+
+	var lines []authorizedKeysLine
+	for i, raw := range strings.Split(text, "\n") {
+		l := authorizedKeysLine{lineNo: i + 1, raw: raw}
+		trimmed := strings.TrimSpace(raw)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			l.key, l.comment, l.options, _, l.err = ssh.ParseAuthorizedKey([]byte(trimmed))
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// writeAuthorizedKeysFile atomically replaces the file with the given lines.
+func writeAuthorizedKeysFile(path string, lines []authorizedKeysLine, perm os.FileMode) error {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l.raw)
+		b.WriteByte('\n')
+	}
+	return writeFileAtomic(path, []byte(b.String()), perm)
+}
+
+// findAuthorizedKey returns the index of the entry with the fingerprint, or -1.
+func findAuthorizedKey(lines []authorizedKeysLine, fingerprint string) int {
+	for i, l := range lines {
+		if l.key != nil && ssh.FingerprintSHA256(l.key) == fingerprint {
+			return i
+		}
+	}
+	return -1
+}
+
+// defaultAuthorizedKeysPath returns ~/.ssh/authorized_keys.
+func defaultAuthorizedKeysPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "authorized_keys"
+	}
+	return filepath.Join(home, ".ssh", "authorized_keys")
+}
+
+// publicKeyBits returns the key size in bits, or 0 when it is unknown.
+func publicKeyBits(pub ssh.PublicKey) int {
+	if cert, ok := pub.(*ssh.Certificate); ok {
+		pub = cert.Key
+	}
+	cpk, ok := pub.(ssh.CryptoPublicKey)
+	if !ok {
+		return 0
+	}
+	switch k := cpk.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	case *dsa.PublicKey:
+		return k.P.BitLen()
+	}
+	return 0
+}
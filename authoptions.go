@@ -0,0 +1,426 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : authoptions.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 12:04:31
+ * Description  : authorized_keys restriction options for tunnel users (builder and TUI step)
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Restriction options placed in front of an authorized_keys entry
+type authorizedKeyOptions struct {
+	Restrict       bool     // restrict: disable everything not re-enabled below
+	PortForwarding bool     // port-forwarding: re-enable forwarding after restrict
+	NoPty          bool     // no-pty
+	PermitOpen     []string // permitopen="host:port", one option per target
+	PermitListen   []string // permitlisten="[host:]port", one option per target
+	From           []string // from="pattern-list"
+	Command        string   // command="..."
+	ExpiryTime     string   // expiry-time="YYYYMMDD[HHMM[SS]][Z]"
+}
+
+// isEmpty reports whether no option is set.
+func (o authorizedKeyOptions) isEmpty() bool {
+	return !o.Restrict && !o.PortForwarding && !o.NoPty &&
+		len(o.PermitOpen) == 0 && len(o.PermitListen) == 0 && len(o.From) == 0 &&
+		o.Command == "" && o.ExpiryTime == ""
+}
+
+// validate checks every option value against the syntax sshd accepts.
+func (o authorizedKeyOptions) validate() error {
+	if o.Restrict && !o.PortForwarding && (len(o.PermitOpen) > 0 || len(o.PermitListen) > 0) {
+		return errors.New("restrict disables port forwarding; add port-forwarding to use permitopen/permitlisten")
+	}
+	for _, target := range o.PermitOpen {
+		if err := validatePermitOpen(target); err != nil {
+			return err
+		}
+	}
+	for _, target := range o.PermitListen {
+		if err := validatePermitListen(target); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range o.From {
+		if err := validateFromPattern(pattern); err != nil {
+			return err
+		}
+	}
+	if o.Command != "" {
+		if err := validateOptionValue("command", o.Command); err != nil {
+			return err
+		}
+	}
+	if o.ExpiryTime != "" {
+		if _, err := parseOpenSSHTime(o.ExpiryTime); err != nil {
+			return fmt.Errorf("expiry-time: %w", err)
+		}
+	}
+	return nil
+}
+
+// String renders the options field of an authorized_keys line.
+func (o authorizedKeyOptions) String() string {
+	var opts []string
+	if o.Restrict {
+		opts = append(opts, "restrict")
+	}
+	if o.PortForwarding {
+		opts = append(opts, "port-forwarding")
+	}
+	if o.NoPty {
+		opts = append(opts, "no-pty")
+	}
+	if len(o.From) > 0 {
+		opts = append(opts, "from="+quoteOptionValue(strings.Join(o.From, ",")))
+	}
+	if o.Command != "" {
+		opts = append(opts, "command="+quoteOptionValue(o.Command))
+	}
+	for _, target := range o.PermitOpen {
+		opts = append(opts, "permitopen="+quoteOptionValue(target))
+	}
+	for _, target := range o.PermitListen {
+		opts = append(opts, "permitlisten="+quoteOptionValue(target))
+	}
+	if o.ExpiryTime != "" {
+		opts = append(opts, "expiry-time="+quoteOptionValue(o.ExpiryTime))
+	}
+	return strings.Join(opts, ",")
+}
+
+// set parses one option in ssh-keygen style (e.g. "permitopen=127.0.0.1:8080").
+func (o *authorizedKeyOptions) set(spec string) error {
+	name, value, hasValue := strings.Cut(spec, "=")
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	switch name {
+	case "restrict", "port-forwarding", "no-pty":
+		if hasValue {
+			return fmt.Errorf("option %s does not take a value", name)
+		}
+		switch name {
+		case "restrict":
+			o.Restrict = true
+		case "port-forwarding":
+			o.PortForwarding = true
+		default:
+			o.NoPty = true
+		}
+		return nil
+	}
+
+	if !hasValue || value == "" {
+		return fmt.Errorf("option %s requires a value", name)
+	}
+	switch name {
+	case "permitopen":
+		o.PermitOpen = append(o.PermitOpen, value)
+	case "permitlisten":
+		o.PermitListen = append(o.PermitListen, value)
+	case "from":
+		o.From = append(o.From, splitOptionList(value)...)
+	case "command":
+		o.Command = value
+	case "expiry-time":
+		o.ExpiryTime = value
+	default:
+		return fmt.Errorf("unsupported authorized_keys option %q", name)
+	}
+	return nil
+}
+
+// applyAuthorizedKeyOptions prefixes a public key line with the options.
+func applyAuthorizedKeyOptions(pubLine []byte, opts authorizedKeyOptions) ([]byte, error) {
+	if opts.isEmpty() {
+		return pubLine, nil
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	line := bytes.TrimRight(pubLine, "\r\n")
+	out := make([]byte, 0, len(line)+64)
+	out = append(out, opts.String()...)
+	out = append(out, ' ')
+	out = append(out, line...)
+	return append(out, '\n'), nil
+}
+
+// quoteOptionValue wraps a value in double quotes. sshd only understands \"
+// as an escape inside quoted values, so that is the only escape emitted.
+func quoteOptionValue(value string) string {
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+// validateOptionValue rejects values that cannot be represented in a quoted option.
+func validateOptionValue(name, value string) error {
+	if value == "" {
+		return fmt.Errorf("%s: empty value", name)
+	}
+	if strings.ContainsAny(value, "\r\n\x00") {
+		return fmt.Errorf("%s: value must not contain line breaks or NUL bytes", name)
+	}
+	if strings.HasSuffix(value, `\`) {
+		// A trailing backslash would escape the closing quote
+		return fmt.Errorf("%s: value must not end with a backslash", name)
+	}
+	return nil
+}
+
+// validatePermitOpen checks a host:port target; the port may be "*".
+func validatePermitOpen(target string) error {
+	if err := validateOptionValue("permitopen", target); err != nil {
+		return err
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil || host == "" {
+		return fmt.Errorf("permitopen: %q is not host:port", target)
+	}
+	return validateOptionPort("permitopen", port)
+}
+
+// validatePermitListen checks a [host:]port target; the port may be "*".
+func validatePermitListen(target string) error {
+	if err := validateOptionValue("permitlisten", target); err != nil {
+		return err
+	}
+	port := target
+	if strings.Contains(target, ":") {
+		var err error
+		_, port, err = net.SplitHostPort(target)
+		if err != nil {
+			return fmt.Errorf("permitlisten: %q is not [host:]port", target)
+		}
+	}
+	return validateOptionPort("permitlisten", port)
+}
+
+// validateOptionPort accepts a port number or the "*" wildcard.
+func validateOptionPort(name, port string) error {
+	if port == "*" {
+		return nil
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("%s: invalid port %q", name, port)
+	}
+	return nil
+}
+
+// validateFromPattern checks one entry of a from= pattern-list: a host or
+// address pattern with * and ? wildcards, or a CIDR, optionally negated with !.
+func validateFromPattern(pattern string) error {
+	if err := validateOptionValue("from", pattern); err != nil {
+		return err
+	}
+	p := strings.TrimPrefix(pattern, "!")
+	if p == "" {
+		return fmt.Errorf("from: empty pattern in %q", pattern)
+	}
+	if strings.Contains(p, "/") {
+		if _, _, err := net.ParseCIDR(p); err != nil {
+			return fmt.Errorf("from: invalid CIDR %q", p)
+		}
+		return nil
+	}
+	for _, r := range p {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune(".-_:*?", r):
+		default:
+			return fmt.Errorf("from: invalid character %q in pattern %q", r, pattern)
+		}
+	}
+	return nil
+}
+
+// parseOpenSSHTime parses the YYYYMMDD[HHMM[SS]] timestamps used by
+// expiry-time, valid-after and valid-before. A trailing Z means UTC,
+// otherwise the time is local.
+func parseOpenSSHTime(value string) (time.Time, error) {
+	loc := time.Local
+	if strings.HasSuffix(value, "Z") || strings.HasSuffix(value, "z") {
+		value = value[:len(value)-1]
+		loc = time.UTC
+	}
+	var layout string
+	switch len(value) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		return time.Time{}, fmt.Errorf("invalid time %q (expected YYYYMMDD[HHMM[SS]][Z])", value)
+	}
+	t, err := time.ParseInLocation(layout, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q (expected YYYYMMDD[HHMM[SS]][Z])", value)
+	}
+	return t, nil
+}
+
+// splitOptionList splits a comma or space separated list, dropping empty items.
+func splitOptionList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+}
+
+// Rows of the options step: three checkboxes followed by text inputs
+var authorizedOptionLabels = []string{
+	"restrict",
+	"port-forwarding",
+	"no-pty",
+	"permitopen",
+	"permitlisten",
+	"from",
+	"command",
+	"expiry-time",
+}
+
+const authorizedOptionToggles = 3
+
+// newAuthorizedOptionInputs creates the text inputs of the options step.
+func newAuthorizedOptionInputs() []textinput.Model {
+	placeholders := []string{
+		"127.0.0.1:8080, 10.0.0.5:22",
+		"localhost:1080",
+		"203.0.113.0/24, *.example.com",
+		"/usr/bin/true",
+		"20271231",
+	}
+	inputs := make([]textinput.Model, len(placeholders))
+	for i, p := range placeholders {
+		inputs[i] = textinput.New()
+		inputs[i].Placeholder = p
+		inputs[i].Prompt = ""
+		inputs[i].CharLimit = 512
+	}
+	return inputs
+}
+
+// collectAuthorizedOptions builds the options from the TUI state.
+func (m model) collectAuthorizedOptions() (authorizedKeyOptions, error) {
+	opts := authorizedKeyOptions{
+		Restrict:       m.optToggles[0],
+		PortForwarding: m.optToggles[1],
+		NoPty:          m.optToggles[2],
+		PermitOpen:     splitOptionList(m.optInputs[0].Value()),
+		PermitListen:   splitOptionList(m.optInputs[1].Value()),
+		From:           splitOptionList(m.optInputs[2].Value()),
+		Command:        strings.TrimSpace(m.optInputs[3].Value()),
+		ExpiryTime:     strings.TrimSpace(m.optInputs[4].Value()),
+	}
+	return opts, opts.validate()
+}
+
+// focusAuthorizedOption moves keyboard focus to the current row.
+func (m model) focusAuthorizedOption() model {
+	for i := range m.optInputs {
+		if i == m.optIdx-authorizedOptionToggles {
+			m.optInputs[i].Focus()
+		} else {
+			m.optInputs[i].Blur()
+		}
+	}
+	return m
+}
+
+// Handle key presses in the options step
+func (m model) updateAuthorizedOptions(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "up", "shift+tab":
+		if m.optIdx > 0 {
+			m.optIdx--
+		}
+		return m.focusAuthorizedOption(), nil
+	case "down", "tab":
+		if m.optIdx < len(authorizedOptionLabels)-1 {
+			m.optIdx++
+		}
+		return m.focusAuthorizedOption(), nil
+	case "esc":
+		m.state = "algorithm_selection"
+		m.optError = ""
+		return m, nil
+	case "enter":
+		opts, err := m.collectAuthorizedOptions()
+		if err != nil {
+			m.optError = err.Error()
+			return m, nil
+		}
+		m.optError = ""
+		m.keyOptions = opts
+		return m.startOrConfirmGeneration()
+	}
+
+	if m.optIdx < authorizedOptionToggles {
+		if msg.String() == " " || msg.String() == "x" {
+			m.optToggles[m.optIdx] = !m.optToggles[m.optIdx]
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	i := m.optIdx - authorizedOptionToggles
+	m.optInputs[i], cmd = m.optInputs[i].Update(msg)
+	return m, cmd
+}
+
+// Render the options step
+func (m model) viewAuthorizedOptions() string {
+	pad := strings.Repeat(" ", padding)
+	view := "\n" +
+		pad + titleStyle.Render(AppTitle) + "\n" +
+		pad + fmt.Sprintf("Version %s", AppVersion) + "\n\n" +
+		pad + "authorized_keys options for the public key (leave empty for none):\n\n"
+
+	for i, label := range authorizedOptionLabels {
+		prefix := "  "
+		if i == m.optIdx {
+			prefix = "▶ "
+		}
+		if i < authorizedOptionToggles {
+			box := "[ ]"
+			if m.optToggles[i] {
+				box = "[x]"
+			}
+			view += pad + prefix + fmt.Sprintf(" %s %s", box, label) + "\n"
+			continue
+		}
+		view += pad + prefix + fmt.Sprintf(" %-13s %s", label+":", m.optInputs[i-authorizedOptionToggles].View()) + "\n"
+	}
+
+	if m.optError != "" {
+		view += "\n" + pad + errorStyle.Render(m.optError) + "\n"
+	}
+	view += "\n" + pad + helpStyle("Use ↑/↓ or Tab to move, Space to toggle, Enter to continue, Esc to go back")
+	return view
+}
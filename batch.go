@@ -0,0 +1,436 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : batch.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 09:15:02
+ * Description  : Batch key generation from CSV/YAML/JSON manifests with a worker pool
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+// Batch result states
+const (
+	batchStatusGenerated = "generated"
+	batchStatusSkipped   = "skipped"
+	batchStatusFailed    = "failed"
+)
+
+// batchRow is one key request read from a manifest.
+type batchRow struct {
+	Name       string `json:"name" yaml:"name"`
+	Algorithm  string `json:"algorithm" yaml:"algorithm"`
+	Size       int    `json:"size,omitempty" yaml:"size,omitempty"`
+	Comment    string `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Path       string `json:"path,omitempty" yaml:"path,omitempty"`
+	Passphrase string `json:"passphrase,omitempty" yaml:"passphrase,omitempty"` // passphrase source, e.g. env:VAR
+}
+
+// batchResult records the outcome of one manifest row.
+type batchResult struct {
+	Row         int    `json:"row" yaml:"row"`
+	Name        string `json:"name" yaml:"name"`
+	Algorithm   string `json:"algorithm" yaml:"algorithm"`
+	Size        int    `json:"size" yaml:"size"`
+	PrivatePath string `json:"private_path" yaml:"private_path"`
+	PublicPath  string `json:"public_path" yaml:"public_path"`
+	Fingerprint string `json:"fingerprint,omitempty" yaml:"fingerprint,omitempty"`
+	Status      string `json:"status" yaml:"status"`
+	Error       string `json:"error,omitempty" yaml:"error,omitempty"`
+	Warning     string `json:"warning,omitempty" yaml:"warning,omitempty"` // failed hooks under the warn policy
+}
+
+// batchJob is a validated row ready for a worker.
+type batchJob struct {
+	index     int
+	row       batchRow
+	algorithm string
+	bits      int
+	private   string
+}
+
+// Run the batch subcommand
+func runBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	workers := fs.Int("workers", runtime.NumCPU(), "number of parallel workers")
+	results := fs.String("results", "", "result manifest path (.json, .yaml or .csv; default <manifest>.results.json)")
+	dir := fs.String("dir", ".", "base directory for relative key paths")
+	force := fs.Bool("force", false, "regenerate rows whose outputs already exist")
+	configPath := fs.String("config", "", "configuration file with generation hooks (default: $"+configEnv+" or the user config directory)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s batch [flags] <manifest.csv|.yaml|.json>\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one manifest file is required")
+	}
+	manifestPath := fs.Arg(0)
+	if *workers < 1 {
+		*workers = 1
+	}
+	if *results == "" {
+		*results = strings.TrimSuffix(manifestPath, filepath.Ext(manifestPath)) + ".results.json"
+	}
+
+	rows, err := readBatchManifest(manifestPath)
+	if err != nil {
+		return err
+	}
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	out := runBatchJobs(rows, *dir, *workers, *force, config, func(done, total int, r batchResult) {
+		switch r.Status {
+		case batchStatusFailed:
+			fmt.Printf("[%d/%d] %s: failed: %s\n", done, total, r.Name, r.Error)
+		default:
+			fmt.Printf("[%d/%d] %s: %s %s\n", done, total, r.Name, r.Status, r.Fingerprint)
+		}
+		if r.Warning != "" {
+			fmt.Printf("[%d/%d] %s: warning: hook failed: %s\n", done, total, r.Name, r.Warning)
+		}
+	})
+
+	if err := writeBatchResults(*results, out); err != nil {
+		return err
+	}
+
+	var generated, skipped, failed int
+	for _, r := range out {
+		switch r.Status {
+		case batchStatusGenerated:
+			generated++
+		case batchStatusSkipped:
+			skipped++
+		default:
+			failed++
+		}
+	}
+	fmt.Printf("Batch complete: %d generated, %d skipped, %d failed\n", generated, skipped, failed)
+	fmt.Printf("Results written to %s\n", *results)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d rows failed", failed, len(out))
+	}
+	return nil
+}
+
+// runBatchJobs validates the rows and generates them across a bounded worker
+// pool, recording every new key in the audit log and running the generation
+// hooks. Results are returned in manifest order; progress is called once per
+// finished row from a single goroutine at a time.
+func runBatchJobs(rows []batchRow, dir string, workers int, force bool, config *appConfig, progress func(done, total int, r batchResult)) []batchResult {
+	results := make([]batchResult, len(rows))
+	var jobs []batchJob
+	seen := make(map[string]int)
+
+	for i, row := range rows {
+		job, err := prepareBatchJob(i, row, dir)
+		results[i] = batchResult{
+			Row:         i + 1,
+			Name:        row.Name,
+			Algorithm:   job.algorithm,
+			Size:        job.bits,
+			PrivatePath: job.private,
+		}
+		if job.private != "" {
+			results[i].PublicPath = job.private + ".pub"
+		}
+		if err == nil && job.private != "" {
+			if prev, dup := seen[filepath.Clean(job.private)]; dup {
+				err = fmt.Errorf("output path %s already used by row %d", job.private, prev+1)
+			}
+			seen[filepath.Clean(job.private)] = i
+		}
+		if err != nil {
+			results[i].Status = batchStatusFailed
+			results[i].Error = err.Error()
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+
+	var (
+		mu   sync.Mutex
+		done int
+	)
+	report := func(i int) {
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		if progress != nil {
+			progress(done, len(rows), results[i])
+		}
+	}
+	for i := range results {
+		if results[i].Status == batchStatusFailed {
+			report(i)
+		}
+	}
+
+	queue := make(chan batchJob)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				r := &results[job.index]
+				fingerprint, status, warning, err := processBatchJob(job, force, config)
+				r.Fingerprint = fingerprint
+				r.Status = status
+				r.Warning = warning
+				if err != nil {
+					r.Error = err.Error()
+				}
+				report(job.index)
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
+
+// prepareBatchJob validates a manifest row and resolves its defaults.
+func prepareBatchJob(index int, row batchRow, dir string) (batchJob, error) {
+	job := batchJob{index: index, row: row}
+
+	algorithm, err := parseAlgorithm(row.Algorithm)
+	if err != nil {
+		return job, err
+	}
+	job.algorithm = algorithm
+
+	info, _ := algorithmInfo(algorithm)
+	job.bits = row.Size
+	if job.bits == 0 {
+		job.bits = info.DefaultSize
+	}
+	if err := validateKeySize(algorithm, job.bits); err != nil {
+		return job, err
+	}
+
+	path := row.Path
+	if path == "" {
+		path = row.Name
+	}
+	if path == "" {
+		return job, errors.New("row needs a name or a path")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	job.private = path
+	return job, nil
+}
+
+// processBatchJob generates a single key, or skips it when both outputs exist.
+// Failed hooks are returned as a warning unless they rolled the key back.
+func processBatchJob(job batchJob, force bool, config *appConfig) (string, string, string, error) {
+	publicPath := job.private + ".pub"
+	privExists, pubExists := fileExists(job.private), fileExists(publicPath)
+
+	if !force {
+		switch {
+		case privExists && pubExists:
+			data, err := os.ReadFile(publicPath)
+			if err != nil {
+				return "", batchStatusFailed, "", err
+			}
+			pub, _, _, _, err := ssh.ParseAuthorizedKey(data)
+			if err != nil {
+				return "", batchStatusFailed, "", fmt.Errorf("existing public key %s: %w", publicPath, err)
+			}
+			return ssh.FingerprintSHA256(pub), batchStatusSkipped, "", nil
+		case privExists:
+			return "", batchStatusFailed, "", fmt.Errorf("partial output: %s exists but %s is missing (use -force to regenerate)", job.private, publicPath)
+		case pubExists:
+			return "", batchStatusFailed, "", fmt.Errorf("partial output: %s exists but %s is missing (use -force to regenerate)", publicPath, job.private)
+		}
+	}
+
+	passphrase, err := resolvePassphrase(job.row.Passphrase)
+	if err != nil {
+		return "", batchStatusFailed, "", err
+	}
+	if dir := filepath.Dir(job.private); dir != "" {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return "", batchStatusFailed, "", err
+		}
+	}
+	privBackup, err := backupKeyFile(job.private)
+	if err != nil {
+		return "", batchStatusFailed, "", err
+	}
+	pubBackup, err := backupKeyFile(publicPath)
+	if err != nil {
+		return "", batchStatusFailed, "", err
+	}
+	pub, err := generateKeyPairFiles(job.algorithm, job.bits, job.row.Comment, job.private, passphrase, privBackup, pubBackup)
+	if err != nil {
+		return "", batchStatusFailed, "", err
+	}
+	_, warnings, err := runWrittenKeyHooks(config, job.private, publicPath, privBackup, pubBackup)
+	if err != nil {
+		return "", batchStatusFailed, "", err
+	}
+	return ssh.FingerprintSHA256(pub), batchStatusGenerated, strings.Join(warnings, "; "), nil
+}
+
+// fileExists reports whether path exists.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// readBatchManifest reads rows from a CSV, YAML or JSON manifest based on its extension.
+func readBatchManifest(path string) ([]batchRow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []batchRow
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		rows, err = parseBatchCSV(bytes.NewReader(data))
+	case ".yaml", ".yml":
+		rows, err = parseBatchStructured(data, yaml.Unmarshal)
+	case ".json":
+		rows, err = parseBatchStructured(data, json.Unmarshal)
+	default:
+		return nil, fmt.Errorf("unsupported manifest format %q (use .csv, .yaml or .json)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing manifest %s: %w", path, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("manifest %s contains no rows", path)
+	}
+	return rows, nil
+}
+
+// parseBatchStructured accepts either a top-level list of rows or an object with a "keys" list.
+func parseBatchStructured(data []byte, unmarshal func([]byte, interface{}) error) ([]batchRow, error) {
+	var rows []batchRow
+	if err := unmarshal(data, &rows); err == nil {
+		return rows, nil
+	}
+	var doc struct {
+		Keys []batchRow `json:"keys" yaml:"keys"`
+	}
+	if err := unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc.Keys, nil
+}
+
+// parseBatchCSV reads a CSV manifest with a header row naming the columns.
+func parseBatchCSV(r io.Reader) ([]batchRow, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["algorithm"]; !ok {
+		return nil, errors.New("CSV header must contain an algorithm column")
+	}
+	field := func(rec []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+
+	var rows []batchRow
+	for n, rec := range records[1:] {
+		row := batchRow{
+			Name:       field(rec, "name"),
+			Algorithm:  field(rec, "algorithm"),
+			Comment:    field(rec, "comment"),
+			Path:       field(rec, "path"),
+			Passphrase: field(rec, "passphrase"),
+		}
+		if size := field(rec, "size"); size != "" {
+			row.Size, err = strconv.Atoi(size)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid size %q", n+2, size)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// writeBatchResults writes the result manifest in the format given by its extension.
+func writeBatchResults(path string, results []batchResult) error {
+	var data []byte
+	var err error
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"row", "name", "algorithm", "size", "private_path", "public_path", "fingerprint", "status", "error", "warning"})
+		for _, r := range results {
+			w.Write([]string{strconv.Itoa(r.Row), r.Name, r.Algorithm, strconv.Itoa(r.Size), r.PrivatePath, r.PublicPath, r.Fingerprint, r.Status, r.Error, r.Warning})
+		}
+		w.Flush()
+		data, err = buf.Bytes(), w.Error()
+	case ".yaml", ".yml":
+		data, err = yaml.Marshal(results)
+	default:
+		data, err = json.MarshalIndent(results, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o644)
+}
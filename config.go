@@ -0,0 +1,79 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : config.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 22:41:09
+ * Description  : Configuration file shared by the interactive and command line modes
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variable naming the configuration file
+const configEnv = "ABDAL_KEYGEN_CONFIG"
+
+// Settings read from the configuration file
+type appConfig struct {
+	Reload   reloadConfig   `yaml:"reload" json:"reload"`
+	Hooks    hooksConfig    `yaml:"hooks" json:"hooks"`
+	AuditLog auditLogConfig `yaml:"audit_log" json:"audit_log"`
+}
+
+// defaultConfigPath returns the configuration file used when none is given:
+// $ABDAL_KEYGEN_CONFIG, or config.yaml in the user configuration directory.
+func defaultConfigPath() string {
+	if path := os.Getenv(configEnv); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "abdal-4iproto-keygen", "config.yaml")
+}
+
+// loadConfig reads a YAML or JSON configuration file. An empty path means the
+// default file, which may be missing; a path given explicitly must exist.
+func loadConfig(path string) (*appConfig, error) {
+	cfg := &appConfig{}
+	explicit := path != ""
+	if !explicit {
+		if path = defaultConfigPath(); path == "" {
+			return cfg, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.Reload.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.Hooks.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,414 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : convert.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 16:24:10
+ * Description  : Converting keys between PEM, DER, PKCS#8 and OpenSSH encodings
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Output formats of the convert command
+const (
+	formatPKCS1   = "pkcs1"    // RSA PRIVATE KEY / RSA PUBLIC KEY
+	formatSEC1    = "sec1"     // EC PRIVATE KEY
+	formatPKCS8   = "pkcs8"    // PRIVATE KEY / ENCRYPTED PRIVATE KEY
+	formatOpenSSH = "openssh"  // openssh-key-v1
+	formatDER     = "der"      // PKCS#8 DER (SubjectPublicKeyInfo for public keys)
+	formatSPKI    = "spki"     // PEM SubjectPublicKeyInfo
+	formatSPKIDER = "spki-der" // DER SubjectPublicKeyInfo
+	formatSSH     = "ssh"      // authorized_keys line
+)
+
+var convertFormats = []string{formatPKCS1, formatSEC1, formatPKCS8, formatOpenSSH, formatDER, formatSPKI, formatSPKIDER, formatSSH}
+
+// A key decoded from any supported encoding
+type decodedKey struct {
+	format    string      // human readable input encoding
+	priv      interface{} // nil for public keys
+	pub       crypto.PublicKey
+	encrypted bool
+	comment   string
+}
+
+// decodeKey detects the encoding of data and decodes it. passphrase is only
+// called for encrypted keys.
+func decodeKey(data []byte, passphrase func() ([]byte, error)) (*decodedKey, error) {
+	k := &decodedKey{}
+	var err error
+
+	if block, _ := pem.Decode(data); block != nil {
+		legacyEncrypted := strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED")
+		switch block.Type {
+		case "RSA PRIVATE KEY", "EC PRIVATE KEY":
+			k.format = "PKCS#1 PEM"
+			if block.Type == "EC PRIVATE KEY" {
+				k.format = "SEC1 PEM"
+			}
+			if legacyEncrypted {
+				k.encrypted = true
+				k.priv, err = parseWithPassphrase(data, passphrase)
+			} else if block.Type == "RSA PRIVATE KEY" {
+				k.priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+			} else {
+				k.priv, err = x509.ParseECPrivateKey(block.Bytes)
+			}
+		case "PRIVATE KEY":
+			k.format = "PKCS#8 PEM"
+			k.priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "ENCRYPTED PRIVATE KEY":
+			k.format, k.encrypted = "encrypted PKCS#8 PEM", true
+			k.priv, err = decryptPKCS8WithPrompt(block.Bytes, passphrase)
+		case "OPENSSH PRIVATE KEY":
+			k.format = "OpenSSH"
+			k.priv, err = ssh.ParseRawPrivateKey(data)
+			var missing *ssh.PassphraseMissingError
+			if errors.As(err, &missing) {
+				k.format, k.encrypted = "encrypted OpenSSH", true
+				k.priv, err = parseWithPassphrase(data, passphrase)
+			}
+		case "PUBLIC KEY":
+			k.format = "SubjectPublicKeyInfo PEM"
+			k.pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			k.format = "PKCS#1 public key PEM"
+			k.pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		default:
+			return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+		}
+	} else if pub, comment, _, _, perr := ssh.ParseAuthorizedKey(data); perr == nil {
+		k.format, k.comment = "OpenSSH public key", comment
+		if _, ok := pub.(*ssh.Certificate); ok {
+			return nil, errors.New("OpenSSH certificates are not supported; use the certified public key")
+		}
+		cpk, ok := pub.(ssh.CryptoPublicKey)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %s", pub.Type())
+		}
+		k.pub = cpk.CryptoPublicKey()
+	} else {
+		err = decodeDERKey(k, data, passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if k.priv != nil {
+		k.priv = normalizePrivateKey(k.priv)
+		signer, err := ssh.NewSignerFromKey(k.priv)
+		if err != nil {
+			return nil, err
+		}
+		k.pub = signer.PublicKey().(ssh.CryptoPublicKey).CryptoPublicKey()
+	}
+	return k, nil
+}
+
+// decodeDERKey tries the binary encodings in turn.
+func decodeDERKey(k *decodedKey, der []byte, passphrase func() ([]byte, error)) error {
+	var err error
+	if isEncryptedPKCS8(der) {
+		k.format, k.encrypted = "encrypted PKCS#8 DER", true
+		k.priv, err = decryptPKCS8WithPrompt(der, passphrase)
+		return err
+	}
+	if priv, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		k.format, k.priv = "PKCS#8 DER", priv
+		return nil
+	}
+	if priv, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		k.format, k.priv = "PKCS#1 DER", priv
+		return nil
+	}
+	if priv, err := x509.ParseECPrivateKey(der); err == nil {
+		k.format, k.priv = "SEC1 DER", priv
+		return nil
+	}
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
+		k.format, k.pub = "SubjectPublicKeyInfo DER", pub
+		return nil
+	}
+	if pub, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		k.format, k.pub = "PKCS#1 public key DER", pub
+		return nil
+	}
+	return errors.New("unrecognised key encoding")
+}
+
+// parseWithPassphrase decodes an encrypted OpenSSH or legacy PEM key.
+func parseWithPassphrase(data []byte, passphrase func() ([]byte, error)) (interface{}, error) {
+	pass, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	return ssh.ParseRawPrivateKeyWithPassphrase(data, pass)
+}
+
+// decryptPKCS8WithPrompt decrypts a PBES2 key with the supplied passphrase.
+func decryptPKCS8WithPrompt(der []byte, passphrase func() ([]byte, error)) (interface{}, error) {
+	pass, err := passphrase()
+	if err != nil {
+		return nil, err
+	}
+	return decryptPKCS8(der, pass)
+}
+
+// encodeKey writes k in the given format. Private formats are encrypted
+// when passphrase is non-empty.
+func encodeKey(k *decodedKey, format string, passphrase []byte, iterations int, comment string) ([]byte, error) {
+	switch format {
+	case formatSPKI, formatSPKIDER:
+		der, err := x509.MarshalPKIXPublicKey(k.pub)
+		if err != nil {
+			return nil, err
+		}
+		if format == formatSPKIDER {
+			return der, nil
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+
+	case formatSSH:
+		pub, err := ssh.NewPublicKey(k.pub)
+		if err != nil {
+			return nil, err
+		}
+		line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+		if comment != "" {
+			line += " " + comment
+		}
+		return []byte(line + "\n"), nil
+	}
+
+	// The remaining formats hold private keys, except PKCS#1 and DER, which
+	// also have a public key form.
+	if k.priv == nil {
+		switch format {
+		case formatPKCS1:
+			rsaPub, ok := k.pub.(*rsa.PublicKey)
+			if !ok {
+				return nil, errors.New("pkcs1 format requires an RSA key")
+			}
+			return pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(rsaPub)}), nil
+		case formatDER:
+			return x509.MarshalPKIXPublicKey(k.pub)
+		}
+		return nil, fmt.Errorf("%s format requires a private key, but the input is a public key", format)
+	}
+
+	if len(passphrase) > 0 && (format == formatPKCS1 || format == formatSEC1) {
+		return nil, fmt.Errorf("%s keys cannot be encrypted securely; use pkcs8 or openssh, or -decrypt", format)
+	}
+	switch format {
+	case formatPKCS1:
+		rsaPriv, ok := k.priv.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("pkcs1 format requires an RSA key")
+		}
+		return encodePrivateKeyToPEM(rsaPriv, AlgorithmRSA)
+	case formatSEC1:
+		ecPriv, ok := k.priv.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("sec1 format requires an ECDSA key")
+		}
+		return encodePrivateKeyToPEM(ecPriv, AlgorithmECDSA)
+	case formatPKCS8, formatDER:
+		var der []byte
+		var err error
+		blockType := "PRIVATE KEY"
+		if len(passphrase) > 0 {
+			der, err = encryptPKCS8(k.priv, passphrase, iterations)
+			blockType = "ENCRYPTED PRIVATE KEY"
+		} else {
+			der, err = x509.MarshalPKCS8PrivateKey(k.priv)
+		}
+		if err != nil || format == formatDER {
+			return der, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), nil
+	case formatOpenSSH:
+		if len(passphrase) > 0 {
+			return encodePrivateKeyWithPassphrase(k.priv, comment, passphrase)
+		}
+		block, err := ssh.MarshalPrivateKey(k.priv, comment)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(block), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (supported: %s)", format, strings.Join(convertFormats, ", "))
+	}
+}
+
+// verifyKeyPair checks that priv signs data that verifies under pub.
+func verifyKeyPair(priv interface{}, pub ssh.PublicKey) error {
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return err
+	}
+	if !keysEqual(signer.PublicKey(), pub) {
+		return errors.New("private key does not match public key")
+	}
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return err
+	}
+	sig, err := signer.Sign(rand.Reader, challenge)
+	if err != nil {
+		return fmt.Errorf("signing challenge: %w", err)
+	}
+	if err := pub.Verify(challenge, sig); err != nil {
+		return fmt.Errorf("verifying challenge signature: %w", err)
+	}
+	return nil
+}
+
+// verifyConversion decodes the converted output and checks it holds the
+// same key as the input.
+func verifyConversion(in *decodedKey, out []byte, passphrase []byte) error {
+	k, err := decodeKey(out, func() ([]byte, error) { return passphrase, nil })
+	if err != nil {
+		return fmt.Errorf("re-reading output: %w", err)
+	}
+	inPub, err := ssh.NewPublicKey(in.pub)
+	if err != nil {
+		return err
+	}
+	outPub, err := ssh.NewPublicKey(k.pub)
+	if err != nil {
+		return err
+	}
+	if !keysEqual(inPub, outPub) {
+		return errors.New("output public key differs from input")
+	}
+	if k.priv != nil {
+		return verifyKeyPair(k.priv, inPub)
+	}
+	return nil
+}
+
+// Run the convert subcommand
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	in := fs.String("i", "", "input key file (encoding is detected automatically)")
+	out := fs.String("o", "", "output file (default: stdout)")
+	format := fs.String("format", formatPKCS8, "output format: "+strings.Join(convertFormats, ", "))
+	passphrase := fs.String("passphrase", "", "passphrase source for an encrypted input key (default: prompt)")
+	newPassphrase := fs.String("new-passphrase", "", "encrypt the output with this passphrase source (env:, file:, pass:)")
+	decrypt := fs.Bool("decrypt", false, "write the output unencrypted")
+	iterations := fs.Int("pbkdf2-iterations", defaultPBKDF2Iterations, "PBKDF2 iterations for encrypted PKCS#8 output")
+	comment := fs.String("C", "", "comment for openssh and ssh output (default: comment of <input>.pub)")
+	force := fs.Bool("force", false, "overwrite the output file")
+	configPath := fs.String("config", "", "configuration file with the audit log (default: $"+configEnv+" or the user config directory)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s convert -i <key> -format <format> [-o <file>] [flags]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if *in == "" {
+		fs.Usage()
+		return errors.New("-i is required")
+	}
+	if *decrypt && *newPassphrase != "" {
+		return errors.New("-decrypt and -new-passphrase are mutually exclusive")
+	}
+	if *out != "" && !*force && fileExists(*out) {
+		return fmt.Errorf("%s already exists (use -force to overwrite)", *out)
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+	var inPass []byte
+	k, err := decodeKey(data, func() ([]byte, error) {
+		var perr error
+		inPass, perr = passphraseOrPrompt(*passphrase, fmt.Sprintf("Enter passphrase for %s: ", *in))
+		return inPass, perr
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", *in, err)
+	}
+
+	// Keep the input encryption unless told otherwise
+	var outPass []byte
+	switch {
+	case *decrypt:
+	case *newPassphrase != "":
+		if outPass, err = resolvePassphrase(*newPassphrase); err != nil {
+			return err
+		}
+	case k.encrypted:
+		outPass = inPass
+	}
+	if k.priv == nil || *format == formatSPKI || *format == formatSPKIDER || *format == formatSSH {
+		outPass = nil
+	}
+
+	if *comment == "" {
+		*comment = k.comment
+		if _, c, err := loadPublicKey(*in + ".pub"); err == nil && *comment == "" {
+			*comment = c
+		}
+	}
+
+	encoded, err := encodeKey(k, *format, outPass, *iterations, *comment)
+	if err != nil {
+		return err
+	}
+	if err := verifyConversion(k, encoded, outPass); err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+
+	if *out == "" {
+		_, err = os.Stdout.Write(encoded)
+		return err
+	}
+	perm := os.FileMode(0o644)
+	if k.priv != nil && *format != formatSPKI && *format != formatSPKIDER && *format != formatSSH {
+		perm = 0o600
+	}
+	if err := writeFileAtomic(*out, encoded, perm); err != nil {
+		return err
+	}
+	if err := logConversion(config, *in, k.pub, *out); err != nil {
+		return err
+	}
+	state := "unencrypted"
+	if len(outPass) > 0 {
+		state = "encrypted"
+	}
+	fmt.Fprintf(os.Stderr, "Converted %s to %s (%s), key pair verified: %s\n", k.format, *format, state, *out)
+	return nil
+}
@@ -0,0 +1,446 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : copyid.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 11:20:08
+ * Description  : Installing public keys on remote servers (ssh-copy-id equivalent)
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// authorized_keys path relative to the remote home directory
+const remoteAuthorizedKeys = ".ssh/authorized_keys"
+
+// Outcome of installing a key on a remote server
+type copyIDReport struct {
+	Fingerprint    string
+	AlreadyPresent bool
+	Changes        []string
+}
+
+// Run the copy-id subcommand
+func runCopyID(args []string) error {
+	fs := flag.NewFlagSet("copy-id", flag.ExitOnError)
+	identity := fs.String("i", "id_rsa.pub", "public key file to install")
+	port := fs.Int("p", 22, "remote SSH port")
+	login := fs.String("l", "", "remote user name (default: current user)")
+	var authKeys stringList
+	fs.Var(&authKeys, "auth-key", "private key used to log in (repeatable; default ~/.ssh/id_*)")
+	passwordEnv := fs.String("password-env", "", "environment variable holding the login password")
+	knownHosts := fs.String("known-hosts", defaultKnownHostsPath(), "known_hosts file used to verify the server")
+	insecure := fs.Bool("insecure", false, "do not verify the server host key")
+	timeout := fs.Duration("timeout", 15*time.Second, "connection timeout")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s copy-id [flags] [user@]host[:port]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("exactly one destination is required")
+	}
+
+	pubLine, err := os.ReadFile(*identity)
+	if err != nil {
+		return err
+	}
+
+	username, addr, err := parseSSHDestination(fs.Arg(0), *login, *port)
+	if err != nil {
+		return err
+	}
+
+	var hostKeyCallback ssh.HostKeyCallback
+	if *insecure {
+		fmt.Fprintln(os.Stderr, "warning: host key verification disabled")
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	} else {
+		hostKeyCallback, err = knownhosts.New(*knownHosts)
+		if err != nil {
+			return fmt.Errorf("loading known_hosts: %w (use -insecure to skip verification)", err)
+		}
+	}
+
+	auth, closeAuth, err := sshAuthMethods(authKeys, *passwordEnv)
+	if err != nil {
+		return err
+	}
+	defer closeAuth()
+
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         *timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	report, err := installPublicKey(client, pubLine)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Key %s on %s@%s\n", report.Fingerprint, username, addr)
+	for _, change := range report.Changes {
+		fmt.Printf("  - %s\n", change)
+	}
+	if report.AlreadyPresent {
+		fmt.Println("Key already present in ~/.ssh/authorized_keys; nothing appended")
+	} else {
+		fmt.Println("Key installed successfully")
+	}
+	return nil
+}
+
+// File operations copy-id performs on the server. Relative names are
+// resolved against the remote home directory.
+type remoteFS interface {
+	Stat(name string) (os.FileMode, error)
+	Mkdir(name string, perm os.FileMode) error
+	Chmod(name string, perm os.FileMode) error
+	ReadFile(name string) ([]byte, error)
+	AppendFile(name string, data []byte, perm os.FileMode) error
+	Close() error
+}
+
+// openRemoteFS uses the server's SFTP subsystem and falls back to POSIX shell
+// commands when the server does not offer it.
+func openRemoteFS(client *ssh.Client) remoteFS {
+	if c, err := newSFTPClient(client); err == nil {
+		return c
+	}
+	return shellFS{client}
+}
+
+// installPublicKey appends the public key line to ~/.ssh/authorized_keys on
+// the server behind client, unless a line with the same key already exists.
+// It creates ~/.ssh and fixes its permissions, and reports what changed.
+func installPublicKey(client *ssh.Client, pubLine []byte) (*copyIDReport, error) {
+	fsys := openRemoteFS(client)
+	defer fsys.Close()
+	return installPublicKeyFS(fsys, pubLine)
+}
+
+// installPublicKeyFS does the work of installPublicKey through fsys.
+func installPublicKeyFS(fsys remoteFS, pubLine []byte) (*copyIDReport, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(pubLine)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key: %w", err)
+	}
+	line := bytes.TrimSpace(pubLine)
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		return nil, errors.New("public key file must contain exactly one key")
+	}
+	report := &copyIDReport{Fingerprint: ssh.FingerprintSHA256(pub)}
+
+	mode, err := fsys.Stat(".ssh")
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := fsys.Mkdir(".ssh", 0700); err != nil {
+			return nil, fmt.Errorf("creating ~/.ssh: %w", err)
+		}
+		report.Changes = append(report.Changes, "created ~/.ssh")
+	case err != nil:
+		return nil, fmt.Errorf("checking ~/.ssh: %w", err)
+	case !mode.IsDir():
+		return nil, errors.New("~/.ssh exists but is not a directory")
+	case mode.Perm() != 0700:
+		if err := fsys.Chmod(".ssh", 0700); err != nil {
+			return nil, fmt.Errorf("setting ~/.ssh permissions: %w", err)
+		}
+		report.Changes = append(report.Changes, "set ~/.ssh permissions to 0700")
+	}
+
+	var existing []byte
+	mode, err = fsys.Stat(remoteAuthorizedKeys)
+	created := errors.Is(err, os.ErrNotExist)
+	switch {
+	case created:
+	case err != nil:
+		return nil, fmt.Errorf("checking authorized_keys: %w", err)
+	case !mode.IsRegular():
+		return nil, errors.New("~/.ssh/authorized_keys exists but is not a regular file")
+	default:
+		if mode.Perm() != 0600 {
+			if err := fsys.Chmod(remoteAuthorizedKeys, 0600); err != nil {
+				return nil, fmt.Errorf("setting authorized_keys permissions: %w", err)
+			}
+			report.Changes = append(report.Changes, "set ~/.ssh/authorized_keys permissions to 0600")
+		}
+		if existing, err = fsys.ReadFile(remoteAuthorizedKeys); err != nil {
+			return nil, fmt.Errorf("reading authorized_keys: %w", err)
+		}
+		if authorizedKeysContain(existing, pub) {
+			report.AlreadyPresent = true
+			return report, nil
+		}
+	}
+
+	data := append(append([]byte(nil), line...), '\n')
+	if len(existing) > 0 && existing[len(existing)-1] != '\n' {
+		data = append([]byte{'\n'}, data...)
+	}
+	if err := fsys.AppendFile(remoteAuthorizedKeys, data, 0600); err != nil {
+		return nil, fmt.Errorf("appending key: %w", err)
+	}
+	if created {
+		report.Changes = append(report.Changes, "created ~/.ssh/authorized_keys")
+	}
+	report.Changes = append(report.Changes, "appended key to ~/.ssh/authorized_keys")
+	return report, nil
+}
+
+// Remote files reached through POSIX shell commands, for servers without SFTP
+type shellFS struct {
+	client *ssh.Client
+}
+
+func (s shellFS) Stat(name string) (os.FileMode, error) {
+	q := shellQuote(name)
+	out, err := runRemote(s.client, "cd && if [ -e "+q+" ]; then ls -ldL "+q+"; fi", nil)
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return 0, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return parseLsMode(fields[0])
+}
+
+func (s shellFS) Mkdir(name string, perm os.FileMode) error {
+	q := shellQuote(name)
+	_, err := runRemote(s.client, fmt.Sprintf("cd && mkdir %s && chmod %o %s", q, perm.Perm(), q), nil)
+	return err
+}
+
+func (s shellFS) Chmod(name string, perm os.FileMode) error {
+	_, err := runRemote(s.client, fmt.Sprintf("cd && chmod %o %s", perm.Perm(), shellQuote(name)), nil)
+	return err
+}
+
+func (s shellFS) ReadFile(name string) ([]byte, error) {
+	return runRemote(s.client, "cd && cat "+shellQuote(name), nil)
+}
+
+func (s shellFS) AppendFile(name string, data []byte, perm os.FileMode) error {
+	_, err := runRemote(s.client, fmt.Sprintf("cd && umask %03o && cat >> %s", ^perm.Perm()&0777, shellQuote(name)), data)
+	return err
+}
+
+func (s shellFS) Close() error {
+	return nil
+}
+
+// parseLsMode converts the mode column of ls -l (drwx------) to an os.FileMode.
+func parseLsMode(field string) (os.FileMode, error) {
+	if len(field) < 10 {
+		return 0, fmt.Errorf("unexpected ls output %q", field)
+	}
+	var mode os.FileMode
+	switch field[0] {
+	case 'd':
+		mode = os.ModeDir
+	case '-':
+	default:
+		mode = os.ModeIrregular
+	}
+	for i, c := range field[1:10] {
+		if c != '-' && c != 'S' && c != 'T' {
+			mode |= 1 << (8 - i)
+		}
+	}
+	return mode, nil
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runRemote runs a command in a new session and returns its standard output.
+func runRemote(client *ssh.Client, cmd string, stdin []byte) ([]byte, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if stdin != nil {
+		session.Stdin = bytes.NewReader(stdin)
+	}
+	if err := session.Run(cmd); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// authorizedKeysContain reports whether any entry in data holds the same key as pub.
+func authorizedKeysContain(data []byte, pub ssh.PublicKey) bool {
+	want := pub.Marshal()
+	for len(data) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return false
+		}
+		if bytes.Equal(key.Marshal(), want) {
+			return true
+		}
+		data = rest
+	}
+	return false
+}
+
+// parseSSHDestination splits [user@]host[:port] into a user name and dial address.
+func parseSSHDestination(dest, login string, port int) (string, string, error) {
+	username := login
+	if at := strings.LastIndex(dest, "@"); at >= 0 {
+		username, dest = dest[:at], dest[at+1:]
+	}
+	if username == "" {
+		u, err := user.Current()
+		if err != nil {
+			return "", "", err
+		}
+		username = u.Username
+	}
+	if dest == "" {
+		return "", "", errors.New("missing host")
+	}
+
+	if host, p, err := net.SplitHostPort(dest); err == nil {
+		return username, net.JoinHostPort(host, p), nil
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(dest, "["), "]")
+	return username, net.JoinHostPort(host, fmt.Sprint(port)), nil
+}
+
+// sshAuthMethods collects login credentials: the running ssh-agent, the given
+// (or default) unencrypted private keys, and an optional password taken from
+// an environment variable. The returned function closes the agent connection.
+func sshAuthMethods(keyFiles []string, passwordEnv string) ([]ssh.AuthMethod, func(), error) {
+	var methods []ssh.AuthMethod
+	closer := func() {}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+			closer = func() { conn.Close() }
+		}
+	}
+
+	explicit := len(keyFiles) > 0
+	if !explicit {
+		if home, err := os.UserHomeDir(); err == nil {
+			for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+				keyFiles = append(keyFiles, filepath.Join(home, ".ssh", name))
+			}
+		}
+	}
+	var signers []ssh.Signer
+	for _, path := range keyFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if explicit {
+				closer()
+				return nil, nil, err
+			}
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			if explicit {
+				closer()
+				return nil, nil, fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if passwordEnv != "" {
+		password, ok := os.LookupEnv(passwordEnv)
+		if !ok {
+			closer()
+			return nil, nil, fmt.Errorf("password environment variable %s is not set", passwordEnv)
+		}
+		methods = append(methods, ssh.Password(password))
+	}
+
+	if len(methods) == 0 {
+		closer()
+		return nil, nil, errors.New("no credentials available (start ssh-agent, use -auth-key or -password-env)")
+	}
+	return methods, closer, nil
+}
+
+// defaultKnownHostsPath returns ~/.ssh/known_hosts.
+func defaultKnownHostsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "known_hosts"
+	}
+	return filepath.Join(home, ".ssh", "known_hosts")
+}
+
+// splitLines returns the non-empty lines of data.
+func splitLines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// Repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
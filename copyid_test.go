@@ -0,0 +1,347 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : copyid_test.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 23:02:44
+ * Description  : Tests for installing public keys on a remote server
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// startCopyIDServer runs an SSH server whose sessions work in home. With
+// sftp set it serves the sftp subsystem; otherwise it runs exec requests with
+// the local shell.
+func startCopyIDServer(t *testing.T, home string, sftp bool) *ssh.Client {
+	t.Helper()
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveCopyIDConn(conn, config, home, sftp)
+		}
+	}()
+
+	client, err := ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+		User:            "tester",
+		HostKeyCallback: ssh.FixedHostKey(hostKey.PublicKey()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func serveCopyIDConn(conn net.Conn, config *ssh.ServerConfig, home string, sftp bool) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer ch.Close()
+			for req := range chReqs {
+				switch {
+				case req.Type == "subsystem" && sftp && string(req.Payload[4:]) == "sftp":
+					req.Reply(true, nil)
+					serveTestSFTP(ch, home)
+					return
+				case req.Type == "exec" && !sftp:
+					req.Reply(true, nil)
+					cmd := exec.Command("sh", "-c", string(req.Payload[4:]))
+					cmd.Env = append(os.Environ(), "HOME="+home)
+					cmd.Stdin, cmd.Stdout, cmd.Stderr = ch, ch, ch.Stderr()
+					status := uint32(0)
+					if err := cmd.Run(); err != nil {
+						status = 1
+					}
+					ch.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, status))
+					return
+				default:
+					req.Reply(false, nil)
+				}
+			}
+		}()
+	}
+}
+
+// serveTestSFTP answers the SFTP requests the client sends, rooted at home.
+func serveTestSFTP(rw io.ReadWriter, home string) {
+	files := map[string]*os.File{}
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for {
+		typ, data, err := readSFTPPacket(rw)
+		if err != nil {
+			return
+		}
+		if typ == sftpInit {
+			writeSFTPPacket(rw, sftpVersion, binary.BigEndian.AppendUint32(nil, 3))
+			continue
+		}
+		b := &sftpBuffer{data: data}
+		id := b.uint32()
+		reply := func(typ byte, payload []byte) {
+			writeSFTPPacket(rw, typ, append(binary.BigEndian.AppendUint32(nil, id), payload...))
+		}
+		status := func(err error) {
+			code := uint32(sftpStatusOK)
+			switch {
+			case errors.Is(err, os.ErrNotExist):
+				code = sftpStatusNoSuchFile
+			case errors.Is(err, io.EOF):
+				code = sftpStatusEOF
+			case err != nil:
+				code = 4
+			}
+			payload := binary.BigEndian.AppendUint32(nil, code)
+			reply(sftpStatus, appendSFTPString(appendSFTPString(payload, nil), nil))
+		}
+		attrs := func(fi os.FileInfo) {
+			perm := uint32(fi.Mode().Perm())
+			if fi.IsDir() {
+				perm |= sftpModeDir
+			} else if fi.Mode().IsRegular() {
+				perm |= sftpModeReg
+			}
+			a := sftpFileAttrs{flags: sftpAttrSize | sftpAttrPermissions, size: uint64(fi.Size()), perm: perm}
+			reply(sftpAttrs, a.appendTo(nil))
+		}
+		path := func(name []byte) string { return filepath.Join(home, string(name)) }
+
+		switch typ {
+		case sftpStat:
+			fi, err := os.Stat(path(b.string()))
+			if err != nil {
+				status(err)
+				continue
+			}
+			attrs(fi)
+		case sftpMkdir:
+			name := b.string()
+			status(os.Mkdir(path(name), os.FileMode(b.attrs().perm)))
+		case sftpSetstat:
+			name := b.string()
+			status(os.Chmod(path(name), os.FileMode(b.attrs().perm)))
+		case sftpOpen:
+			name, pflags, a := b.string(), b.uint32(), b.attrs()
+			flag := os.O_RDONLY
+			if pflags&sftpFlagWrite != 0 {
+				flag = os.O_WRONLY
+			}
+			if pflags&sftpFlagCreate != 0 {
+				flag |= os.O_CREATE
+			}
+			f, err := os.OpenFile(path(name), flag, os.FileMode(a.perm))
+			if err != nil {
+				status(err)
+				continue
+			}
+			handle := string(name)
+			files[handle] = f
+			reply(sftpHandle, appendSFTPString(nil, []byte(handle)))
+		case sftpFstat:
+			fi, err := files[string(b.string())].Stat()
+			if err != nil {
+				status(err)
+				continue
+			}
+			attrs(fi)
+		case sftpRead:
+			f, offset, n := files[string(b.string())], b.uint64(), b.uint32()
+			buf := make([]byte, n)
+			n2, err := f.ReadAt(buf, int64(offset))
+			if n2 == 0 {
+				status(err)
+				continue
+			}
+			reply(sftpData, appendSFTPString(nil, buf[:n2]))
+		case sftpWrite:
+			f, offset, chunk := files[string(b.string())], b.uint64(), b.string()
+			_, err := f.WriteAt(chunk, int64(offset))
+			status(err)
+		case sftpClose:
+			handle := string(b.string())
+			status(files[handle].Close())
+			delete(files, handle)
+		default:
+			status(errors.New("unsupported"))
+		}
+	}
+}
+
+// testPublicKeyLine returns a fresh authorized_keys line.
+func testPublicKeyLine(t *testing.T, comment string) []byte {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+	return []byte(line + " " + comment + "\n")
+}
+
+// checkMode fails unless path has the given permissions.
+func checkMode(t *testing.T, path string, want os.FileMode) {
+	t.Helper()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fi.Mode().Perm(); got != want {
+		t.Errorf("%s has mode %04o, want %04o", path, got, want)
+	}
+}
+
+func testInstallPublicKey(t *testing.T, sftp bool) {
+	home := t.TempDir()
+	client := startCopyIDServer(t, home, sftp)
+	line := testPublicKeyLine(t, "first@host")
+
+	// Fresh account: ~/.ssh and authorized_keys are created
+	report, err := installPublicKey(client, line)
+	if err != nil {
+		t.Fatalf("installPublicKey: %v", err)
+	}
+	want := []string{"created ~/.ssh", "created ~/.ssh/authorized_keys", "appended key to ~/.ssh/authorized_keys"}
+	if report.AlreadyPresent || !reflect.DeepEqual(report.Changes, want) {
+		t.Fatalf("report = %+v, want changes %q", report, want)
+	}
+	authKeys := filepath.Join(home, ".ssh", "authorized_keys")
+	data, err := os.ReadFile(authKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(line) {
+		t.Fatalf("authorized_keys = %q, want %q", data, line)
+	}
+	checkMode(t, filepath.Join(home, ".ssh"), 0700)
+	checkMode(t, authKeys, 0600)
+
+	// Same key with a different comment and options is a duplicate
+	duplicate := append([]byte(`no-pty `), strings.Replace(string(line), "first@host", "other", 1)...)
+	report, err = installPublicKey(client, duplicate)
+	if err != nil {
+		t.Fatalf("installPublicKey duplicate: %v", err)
+	}
+	if !report.AlreadyPresent || len(report.Changes) != 0 {
+		t.Fatalf("duplicate report = %+v", report)
+	}
+	if after, _ := os.ReadFile(authKeys); string(after) != string(data) {
+		t.Fatalf("duplicate changed authorized_keys to %q", after)
+	}
+
+	// Loose permissions are fixed and a missing final newline is added
+	if err := os.WriteFile(authKeys, []byte(strings.TrimSuffix(string(data), "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(authKeys, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(home, ".ssh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	second := testPublicKeyLine(t, "second@host")
+	report, err = installPublicKey(client, second)
+	if err != nil {
+		t.Fatalf("installPublicKey second key: %v", err)
+	}
+	want = []string{"set ~/.ssh permissions to 0700", "set ~/.ssh/authorized_keys permissions to 0600", "appended key to ~/.ssh/authorized_keys"}
+	if report.AlreadyPresent || !reflect.DeepEqual(report.Changes, want) {
+		t.Fatalf("second report = %+v, want changes %q", report, want)
+	}
+	if after, _ := os.ReadFile(authKeys); string(after) != string(line)+string(second) {
+		t.Fatalf("authorized_keys = %q, want both keys", after)
+	}
+	checkMode(t, filepath.Join(home, ".ssh"), 0700)
+	checkMode(t, authKeys, 0600)
+}
+
+func TestInstallPublicKeySFTP(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix permissions required")
+	}
+	testInstallPublicKey(t, true)
+}
+
+func TestInstallPublicKeyShellFallback(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix permissions required")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no POSIX shell available")
+	}
+	testInstallPublicKey(t, false)
+}
+
+func TestInstallPublicKeyRejectsFileAsSSHDir(t *testing.T) {
+	home := t.TempDir()
+	if err := os.WriteFile(filepath.Join(home, ".ssh"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	client := startCopyIDServer(t, home, true)
+	if _, err := installPublicKey(client, testPublicKeyLine(t, "x")); err == nil {
+		t.Fatal("installed a key although ~/.ssh is a file")
+	}
+}
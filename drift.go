@@ -0,0 +1,283 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : drift.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 20:21:09
+ * Description  : Drift between two key inventory snapshots
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of drift
+const (
+	driftAdded      = "added"
+	driftRemoved    = "removed"
+	driftRotated    = "rotated"
+	driftPermission = "permission"
+)
+
+// One change between two snapshots
+type driftChange struct {
+	Change         string `json:"change"`
+	Host           string `json:"host"`
+	Path           string `json:"path"`
+	Line           int    `json:"line,omitempty"`
+	Kind           string `json:"kind,omitempty"`
+	Algorithm      string `json:"algorithm,omitempty"`
+	OldFingerprint string `json:"old_fingerprint,omitempty"`
+	NewFingerprint string `json:"new_fingerprint,omitempty"`
+	OldMode        string `json:"old_mode,omitempty"`
+	NewMode        string `json:"new_mode,omitempty"`
+}
+
+// How much drift is acceptable; -1 allows any number of changes
+type driftPolicy struct {
+	MaxAdded      int `json:"max_added" yaml:"max_added"`
+	MaxRemoved    int `json:"max_removed" yaml:"max_removed"`
+	MaxRotated    int `json:"max_rotated" yaml:"max_rotated"`
+	MaxPermission int `json:"max_permission" yaml:"max_permission"`
+}
+
+// driftKey identifies a key across snapshots. Single-key files are matched by
+// path, so a new key at the same path is a rotation; entries of multi-key
+// files such as authorized_keys or certificate bundles are matched by
+// fingerprint, since lines move, or by line when the fingerprint is unknown.
+func driftKey(it inventoryItem) string {
+	if it.Line == 0 {
+		return it.Host + "\x00" + it.Path + "\x00" + it.Kind
+	}
+	if it.Fingerprint == "" {
+		return it.Host + "\x00" + it.Path + "\x00" + it.Kind + "\x00" + strconv.Itoa(it.Line)
+	}
+	return it.Host + "\x00" + it.Path + "\x00" + it.Kind + "\x00" + it.Fingerprint
+}
+
+// diffInventories lists the keys added, removed and rotated between two
+// snapshots, and the files whose permissions changed.
+func diffInventories(old, cur []inventoryItem) []driftChange {
+	oldKeys := map[string]inventoryItem{}
+	for _, it := range old {
+		oldKeys[driftKey(it)] = it
+	}
+	curKeys := map[string]inventoryItem{}
+	for _, it := range cur {
+		curKeys[driftKey(it)] = it
+	}
+
+	var changes []driftChange
+	for k, it := range curKeys {
+		prev, ok := oldKeys[k]
+		switch {
+		case !ok:
+			changes = append(changes, driftChange{Change: driftAdded, Host: it.Host, Path: it.Path, Line: it.Line,
+				Kind: it.Kind, Algorithm: it.Algorithm, NewFingerprint: it.Fingerprint})
+		case prev.Fingerprint != it.Fingerprint:
+			changes = append(changes, driftChange{Change: driftRotated, Host: it.Host, Path: it.Path,
+				Kind: it.Kind, Algorithm: it.Algorithm, OldFingerprint: prev.Fingerprint, NewFingerprint: it.Fingerprint})
+		}
+	}
+	for k, it := range oldKeys {
+		if _, ok := curKeys[k]; !ok {
+			changes = append(changes, driftChange{Change: driftRemoved, Host: it.Host, Path: it.Path, Line: it.Line,
+				Kind: it.Kind, Algorithm: it.Algorithm, OldFingerprint: it.Fingerprint})
+		}
+	}
+
+	// Permissions belong to files, not keys
+	oldModes := map[string]string{}
+	for _, it := range old {
+		oldModes[it.Host+"\x00"+it.Path] = it.Mode
+	}
+	seen := map[string]bool{}
+	for _, it := range cur {
+		file := it.Host + "\x00" + it.Path
+		if seen[file] {
+			continue
+		}
+		seen[file] = true
+		if mode, ok := oldModes[file]; ok && mode != it.Mode {
+			changes = append(changes, driftChange{Change: driftPermission, Host: it.Host, Path: it.Path,
+				Kind: it.Kind, OldMode: mode, NewMode: it.Mode})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Host != b.Host {
+			return a.Host < b.Host
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Change < b.Change
+	})
+	return changes
+}
+
+// check returns the policy limits the changes exceed.
+func (p driftPolicy) check(changes []driftChange) []string {
+	counts := map[string]int{}
+	for _, c := range changes {
+		counts[c.Change]++
+	}
+	var violations []string
+	for _, limit := range []struct {
+		change string
+		max    int
+	}{
+		{driftAdded, p.MaxAdded},
+		{driftRemoved, p.MaxRemoved},
+		{driftRotated, p.MaxRotated},
+		{driftPermission, p.MaxPermission},
+	} {
+		if limit.max >= 0 && counts[limit.change] > limit.max {
+			violations = append(violations, fmt.Sprintf("%d %s (allowed %d)", counts[limit.change], limit.change, limit.max))
+		}
+	}
+	return violations
+}
+
+// readDriftPolicy loads a YAML or JSON policy file over the defaults.
+func readDriftPolicy(path string, policy *driftPolicy) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Run the drift subcommand
+func runDrift(args []string) error {
+	fs := flag.NewFlagSet("drift", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text or json")
+	policyFile := fs.String("policy", "", "YAML or JSON policy file with max_added, max_removed, max_rotated and max_permission")
+	maxAdded := fs.Int("max-added", 0, "largest acceptable number of added keys (-1 = any)")
+	maxRemoved := fs.Int("max-removed", 0, "largest acceptable number of removed keys (-1 = any)")
+	maxRotated := fs.Int("max-rotated", 0, "largest acceptable number of rotated keys (-1 = any)")
+	maxPermission := fs.Int("max-permission", 0, "largest acceptable number of permission changes (-1 = any)")
+	host := fs.String("host", "", "host name recorded for scanned paths (default: this host)")
+	quiet := fs.Bool("q", false, "do not print unreadable paths")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s drift [flags] <old.json> <new.json|dir|file>...\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return errors.New("an old snapshot and a new snapshot or paths to scan are required")
+	}
+
+	// Flags given on the command line override the policy file
+	policy := driftPolicy{MaxAdded: *maxAdded, MaxRemoved: *maxRemoved, MaxRotated: *maxRotated, MaxPermission: *maxPermission}
+	if *policyFile != "" {
+		if err := readDriftPolicy(*policyFile, &policy); err != nil {
+			return err
+		}
+		fs.Visit(func(f *flag.Flag) {
+			n, _ := strconv.Atoi(f.Value.String())
+			switch f.Name {
+			case "max-added":
+				policy.MaxAdded = n
+			case "max-removed":
+				policy.MaxRemoved = n
+			case "max-rotated":
+				policy.MaxRotated = n
+			case "max-permission":
+				policy.MaxPermission = n
+			}
+		})
+	}
+
+	if *host == "" {
+		*host, _ = os.Hostname()
+	}
+	warn := func(err error) {
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
+	old, err := readInventory(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	cur, err := loadInventories(fs.Args()[1:], *host, scanPolicy{}, warn)
+	if err != nil {
+		return err
+	}
+
+	changes := diffInventories(old.Items, cur)
+	switch *format {
+	case "json":
+		data, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "text":
+		printDrift(changes)
+	default:
+		return fmt.Errorf("unknown format %q (text or json)", *format)
+	}
+
+	if violations := policy.check(changes); len(violations) > 0 {
+		return fmt.Errorf("drift exceeds policy: %s", strings.Join(violations, ", "))
+	}
+	return nil
+}
+
+// printDrift prints one line per change.
+func printDrift(changes []driftChange) {
+	if len(changes) == 0 {
+		fmt.Println("No drift")
+		return
+	}
+	counts := map[string]int{}
+	for _, c := range changes {
+		counts[c.Change]++
+		where := c.Host + ":" + c.Path
+		if c.Line > 0 {
+			where += ":" + strconv.Itoa(c.Line)
+		}
+		switch c.Change {
+		case driftAdded:
+			fmt.Printf("+ %s %s %s %s\n", where, c.Kind, c.Algorithm, c.NewFingerprint)
+		case driftRemoved:
+			fmt.Printf("- %s %s %s %s\n", where, c.Kind, c.Algorithm, c.OldFingerprint)
+		case driftRotated:
+			fmt.Printf("~ %s %s %s %s -> %s\n", where, c.Kind, c.Algorithm, c.OldFingerprint, c.NewFingerprint)
+		case driftPermission:
+			fmt.Printf("! %s mode %s -> %s\n", where, c.OldMode, c.NewMode)
+		}
+	}
+	fmt.Printf("\n%d added, %d removed, %d rotated, %d permission changes\n",
+		counts[driftAdded], counts[driftRemoved], counts[driftRotated], counts[driftPermission])
+}
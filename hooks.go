@@ -0,0 +1,311 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : hooks.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 23:02:44
+ * Description  : Executable and HTTP hooks run after a key has been generated
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// What happens to the new key when a hook fails
+const (
+	hookFailureWarn     = "warn"     // keep the key and report the failure
+	hookFailureRollback = "rollback" // restore the previous files
+)
+
+// Header carrying the HMAC-SHA256 of the request body
+const hookSignatureHeader = "X-Keygen-Signature"
+
+// Hooks run after every successful generation
+type hooksConfig struct {
+	OnFailure string     `yaml:"on_failure" json:"on_failure"` // warn (default) or rollback
+	Exec      []execHook `yaml:"exec" json:"exec"`
+	HTTP      []httpHook `yaml:"http" json:"http"`
+}
+
+// Executable receiving the key metadata in its environment and as JSON on stdin
+type execHook struct {
+	Command string        `yaml:"command" json:"command"`
+	Args    []string      `yaml:"args" json:"args"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout"` // default 30s
+}
+
+// Endpoint receiving the key metadata as a JSON POST
+type httpHook struct {
+	URL        string            `yaml:"url" json:"url"`
+	Headers    map[string]string `yaml:"headers" json:"headers"`
+	HMACSecret string            `yaml:"hmac_secret" json:"hmac_secret"` // secret source: env:, file: or pass:
+	Retries    *int              `yaml:"retries" json:"retries"`         // extra attempts, default 3; 0 disables retries
+	Backoff    time.Duration     `yaml:"backoff" json:"backoff"`         // first retry delay, doubled each time, default 1s
+	Timeout    time.Duration     `yaml:"timeout" json:"timeout"`         // per attempt, default 10s
+}
+
+// Metadata of a generated key passed to the hooks. It never holds private
+// material.
+type keyEvent struct {
+	Event       string    `json:"event"`
+	Time        time.Time `json:"time"`
+	Host        string    `json:"host"`
+	PrivatePath string    `json:"private_path"`
+	PublicPath  string    `json:"public_path"`
+	Algorithm   string    `json:"algorithm"`
+	Bits        int       `json:"bits,omitempty"`
+	Fingerprint string    `json:"fingerprint"`
+	Comment     string    `json:"comment,omitempty"`
+	PublicKey   string    `json:"public_key"`
+}
+
+// enabled reports whether any hook is configured.
+func (c hooksConfig) enabled() bool {
+	return len(c.Exec) > 0 || len(c.HTTP) > 0
+}
+
+// validate checks the failure policy and that every hook has a target.
+func (c hooksConfig) validate() error {
+	switch c.OnFailure {
+	case "", hookFailureWarn, hookFailureRollback:
+	default:
+		return fmt.Errorf("hooks: on_failure must be %s or %s, not %q", hookFailureWarn, hookFailureRollback, c.OnFailure)
+	}
+	for i, h := range c.Exec {
+		if h.Command == "" {
+			return fmt.Errorf("hooks: exec hook %d has no command", i+1)
+		}
+	}
+	for i, h := range c.HTTP {
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("hooks: http hook %d needs an http or https url", i+1)
+		}
+		if h.Retries != nil && *h.Retries < 0 {
+			return fmt.Errorf("hooks: http hook %d has negative retries", i+1)
+		}
+	}
+	return nil
+}
+
+// newKeyEvent describes a key pair just written with absolute paths. event is
+// generated or overwritten.
+func newKeyEvent(event, privatePath, publicPath string, pub ssh.PublicKey, comment string) keyEvent {
+	host, _ := os.Hostname()
+	if abs, err := filepath.Abs(privatePath); err == nil {
+		privatePath = abs
+	}
+	if abs, err := filepath.Abs(publicPath); err == nil {
+		publicPath = abs
+	}
+	return keyEvent{
+		Event:       event,
+		Time:        time.Now().UTC().Truncate(time.Second),
+		Host:        host,
+		PrivatePath: privatePath,
+		PublicPath:  publicPath,
+		Algorithm:   pub.Type(),
+		Bits:        publicKeyBits(pub),
+		Fingerprint: ssh.FingerprintSHA256(pub),
+		Comment:     comment,
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))),
+	}
+}
+
+// environment returns the KEYGEN_* variables passed to executable hooks.
+func (ev keyEvent) environment() []string {
+	return []string{
+		"KEYGEN_EVENT=" + ev.Event,
+		"KEYGEN_TIME=" + ev.Time.Format(time.RFC3339),
+		"KEYGEN_HOST=" + ev.Host,
+		"KEYGEN_PRIVATE_PATH=" + ev.PrivatePath,
+		"KEYGEN_PUBLIC_PATH=" + ev.PublicPath,
+		"KEYGEN_ALGORITHM=" + ev.Algorithm,
+		"KEYGEN_BITS=" + strconv.Itoa(ev.Bits),
+		"KEYGEN_FINGERPRINT=" + ev.Fingerprint,
+		"KEYGEN_COMMENT=" + ev.Comment,
+	}
+}
+
+// runKeyHooks runs every configured hook for the key pair just written.
+// Under the rollback policy a failure restores the backups and is returned as
+// an error; otherwise failures are returned as warnings and the key is kept.
+func runKeyHooks(c hooksConfig, privatePath, publicPath string, backups ...keyFileBackup) (done, warnings []string, err error) {
+	if !c.enabled() {
+		return nil, nil, nil
+	}
+	var failures []string
+	if pub, comment, err := loadPublicKey(publicPath); err != nil {
+		failures = append(failures, err.Error())
+	} else {
+		ev := newKeyEvent(writtenEvent(backups...), privatePath, publicPath, pub, comment)
+		body, err := json.Marshal(ev)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, h := range c.Exec {
+			if result, err := h.run(ev, body); err != nil {
+				failures = append(failures, err.Error())
+			} else {
+				done = append(done, result)
+			}
+		}
+		for _, h := range c.HTTP {
+			if result, err := h.post(body); err != nil {
+				failures = append(failures, err.Error())
+			} else {
+				done = append(done, result)
+			}
+		}
+	}
+
+	if len(failures) > 0 && c.OnFailure == hookFailureRollback {
+		return done, nil, rollbackKeyFiles(fmt.Errorf("hook failed: %s", strings.Join(failures, "; ")), backups...)
+	}
+	return done, failures, nil
+}
+
+// runWrittenKeyHooks records a key pair just written in the audit log and then
+// runs the hooks, so no hook is told about a key the log does not know. When a
+// hook failure rolls the key back, the rollback is recorded as well.
+func runWrittenKeyHooks(cfg *appConfig, privatePath, publicPath string, backups ...keyFileBackup) (done, warnings []string, err error) {
+	if err := logKeyPairWritten(cfg, privatePath, publicPath, backups...); err != nil {
+		return nil, nil, err
+	}
+	pub, _, pubErr := loadPublicKey(publicPath)
+	done, warnings, err = runKeyHooks(cfg.Hooks, privatePath, publicPath, backups...)
+	if err != nil && pubErr == nil {
+		if logErr := logKeyEvent(cfg, newAuditRecord(auditRolledBack, pub, privatePath, publicPath)); logErr != nil {
+			err = fmt.Errorf("%w; %v", err, logErr)
+		}
+	}
+	return done, warnings, err
+}
+
+// run executes the hook with the key metadata in its environment and the
+// JSON document on stdin.
+func (h execHook) run(ev keyEvent, body []byte) (string, error) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.Command, h.Args...)
+	cmd.Env = append(os.Environ(), ev.environment()...)
+	cmd.Stdin = bytes.NewReader(body)
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%s timed out after %s", h.Command, timeout)
+	}
+	if err != nil {
+		if output != "" {
+			return "", fmt.Errorf("%s: %v: %s", h.Command, err, output)
+		}
+		return "", fmt.Errorf("%s: %w", h.Command, err)
+	}
+	if output != "" {
+		return fmt.Sprintf("ran %s: %s", h.Command, output), nil
+	}
+	return "ran " + h.Command, nil
+}
+
+// post sends the JSON document to the endpoint, retrying network errors, 429
+// and 5xx responses with exponential backoff.
+func (h httpHook) post(body []byte) (string, error) {
+	var signature string
+	if h.HMACSecret != "" {
+		secret, err := resolvePassphrase(h.HMACSecret)
+		if err != nil {
+			return "", fmt.Errorf("%s: hmac secret: %w", h.URL, err)
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(body)
+		signature = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	retries, backoff, timeout := 3, h.Backoff, h.Timeout
+	if h.Retries != nil {
+		retries = *h.Retries
+	}
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "abdal-4iproto-keygen/"+AppVersion)
+		for k, v := range h.Headers {
+			req.Header.Set(k, v)
+		}
+		if signature != "" {
+			req.Header.Set(hookSignatureHeader, signature)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return fmt.Sprintf("posted to %s (%s)", h.URL, resp.Status), nil
+		}
+		lastErr = errors.New(resp.Status)
+		if text := strings.TrimSpace(string(msg)); text != "" {
+			lastErr = fmt.Errorf("%s: %s", resp.Status, text)
+		}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			// the request itself was rejected; repeating it will not help
+			break
+		}
+	}
+	var urlErr *url.Error
+	if errors.As(lastErr, &urlErr) {
+		lastErr = urlErr.Err
+	}
+	return "", fmt.Errorf("posting to %s: %w", h.URL, lastErr)
+}
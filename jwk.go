@@ -0,0 +1,541 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : jwk.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 16:58:36
+ * Description  : JSON Web Key (RFC 7517) export and import
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// A JSON Web Key. Private members are omitted for public keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	D   string `json:"d,omitempty"`
+	P   string `json:"p,omitempty"`
+	Q   string `json:"q,omitempty"`
+	DP  string `json:"dp,omitempty"`
+	DQ  string `json:"dq,omitempty"`
+	QI  string `json:"qi,omitempty"`
+}
+
+// A JSON Web Key Set
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// jwkEncode returns the unpadded base64url form of b.
+func jwkEncode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// jwkDecode decodes a base64url member, tolerating padding.
+func jwkDecode(name, s string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid %q member", name)
+	}
+	return b, nil
+}
+
+// jwkInt decodes a base64url member as an unsigned integer.
+func jwkInt(name, s string) (*big.Int, error) {
+	b, err := jwkDecode(name, s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// jwkCurve maps EC curves to their JWK name, default alg and coordinate size.
+func jwkCurve(curve elliptic.Curve) (crv, alg string, size int, err error) {
+	switch curve {
+	case elliptic.P256():
+		return "P-256", "ES256", 32, nil
+	case elliptic.P384():
+		return "P-384", "ES384", 48, nil
+	case elliptic.P521():
+		return "P-521", "ES512", 66, nil
+	default:
+		return "", "", 0, errors.New("unsupported EC curve")
+	}
+}
+
+// newJWK builds a JWK for a public key, with the private members when priv is non-nil.
+func newJWK(pub crypto.PublicKey, priv interface{}) (*jwk, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		j := &jwk{Kty: "RSA", Alg: "RS256", N: jwkEncode(k.N.Bytes()), E: jwkEncode(big.NewInt(int64(k.E)).Bytes())}
+		if p, ok := priv.(*rsa.PrivateKey); ok {
+			if len(p.Primes) != 2 {
+				return nil, errors.New("JWK export supports only two-prime RSA keys")
+			}
+			p.Precompute()
+			j.D = jwkEncode(p.D.Bytes())
+			j.P = jwkEncode(p.Primes[0].Bytes())
+			j.Q = jwkEncode(p.Primes[1].Bytes())
+			j.DP = jwkEncode(p.Precomputed.Dp.Bytes())
+			j.DQ = jwkEncode(p.Precomputed.Dq.Bytes())
+			j.QI = jwkEncode(p.Precomputed.Qinv.Bytes())
+		}
+		return j, nil
+
+	case *ecdsa.PublicKey:
+		crv, alg, size, err := jwkCurve(k.Curve)
+		if err != nil {
+			return nil, err
+		}
+		j := &jwk{Kty: "EC", Alg: alg, Crv: crv, X: jwkEncode(k.X.FillBytes(make([]byte, size))), Y: jwkEncode(k.Y.FillBytes(make([]byte, size)))}
+		if p, ok := priv.(*ecdsa.PrivateKey); ok {
+			j.D = jwkEncode(p.D.FillBytes(make([]byte, size)))
+		}
+		return j, nil
+
+	case ed25519.PublicKey:
+		j := &jwk{Kty: "OKP", Alg: "EdDSA", Crv: "Ed25519", X: jwkEncode(k)}
+		if p, ok := priv.(ed25519.PrivateKey); ok {
+			j.D = jwkEncode(p.Seed())
+		}
+		return j, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}
+
+// thumbprint returns the RFC 7638 SHA-256 thumbprint of the key.
+func (j *jwk) thumbprint() (string, error) {
+	// Required members only, in lexicographic order, without whitespace
+	var members []string
+	switch j.Kty {
+	case "RSA":
+		members = []string{"e", j.E, "kty", j.Kty, "n", j.N}
+	case "EC":
+		members = []string{"crv", j.Crv, "kty", j.Kty, "x", j.X, "y", j.Y}
+	case "OKP":
+		members = []string{"crv", j.Crv, "kty", j.Kty, "x", j.X}
+	default:
+		return "", fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i := 0; i < len(members); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(members[i])
+		value, _ := json.Marshal(members[i+1])
+		b.Write(name)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	sum := sha256.Sum256(b.Bytes())
+	return jwkEncode(sum[:]), nil
+}
+
+// setAlg applies a user chosen alg after checking it fits the key.
+func (j *jwk) setAlg(alg string) error {
+	if alg == "" {
+		return nil
+	}
+	var allowed []string
+	switch j.Kty {
+	case "RSA":
+		allowed = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "RSA-OAEP", "RSA-OAEP-256"}
+	case "EC":
+		// The curve fixes the signature algorithm
+		allowed = []string{j.Alg, "ECDH-ES", "ECDH-ES+A128KW", "ECDH-ES+A256KW"}
+	case "OKP":
+		allowed = []string{"EdDSA"}
+	}
+	if !containsString(allowed, alg) {
+		return fmt.Errorf("alg %q is not valid for %s keys (allowed: %s)", alg, j.describe(), strings.Join(allowed, ", "))
+	}
+	j.Alg = alg
+	return nil
+}
+
+// describe returns the key type and curve for messages.
+func (j *jwk) describe() string {
+	if j.Crv != "" {
+		return j.Kty + " " + j.Crv
+	}
+	return j.Kty
+}
+
+// isPrivate reports whether the JWK carries private key material.
+func (j *jwk) isPrivate() bool {
+	return j.D != ""
+}
+
+// key converts the JWK to a crypto public key and, when present, private key.
+func (j *jwk) key() (crypto.PublicKey, interface{}, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := jwkInt("n", j.N)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := jwkInt("e", j.E)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, nil, errors.New("invalid RSA exponent")
+		}
+		pub := &rsa.PublicKey{N: n, E: int(e.Int64())}
+		if !j.isPrivate() {
+			return pub, nil, nil
+		}
+		d, err := jwkInt("d", j.D)
+		if err != nil {
+			return nil, nil, err
+		}
+		p, err := jwkInt("p", j.P)
+		if err != nil {
+			return nil, nil, err
+		}
+		q, err := jwkInt("q", j.Q)
+		if err != nil {
+			return nil, nil, err
+		}
+		priv := &rsa.PrivateKey{PublicKey: *pub, D: d, Primes: []*big.Int{p, q}}
+		if err := priv.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("invalid RSA private key: %w", err)
+		}
+		priv.Precompute()
+		return pub, priv, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil, fmt.Errorf("unsupported EC curve %q", j.Crv)
+		}
+		x, err := jwkInt("x", j.X)
+		if err != nil {
+			return nil, nil, err
+		}
+		y, err := jwkInt("y", j.Y)
+		if err != nil {
+			return nil, nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		if _, err := pub.ECDH(); err != nil {
+			return nil, nil, errors.New("EC point is not on the curve")
+		}
+		if !j.isPrivate() {
+			return pub, nil, nil
+		}
+		d, err := jwkInt("d", j.D)
+		if err != nil {
+			return nil, nil, err
+		}
+		if d.Sign() <= 0 || d.Cmp(curve.Params().N) >= 0 {
+			return nil, nil, errors.New("EC private scalar out of range")
+		}
+		px, py := curve.ScalarBaseMult(d.Bytes())
+		if px.Cmp(x) != 0 || py.Cmp(y) != 0 {
+			return nil, nil, errors.New("EC private key does not match public key")
+		}
+		return pub, &ecdsa.PrivateKey{PublicKey: *pub, D: d}, nil
+
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, nil, fmt.Errorf("unsupported OKP curve %q", j.Crv)
+		}
+		x, err := jwkDecode("x", j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, nil, errors.New("invalid Ed25519 public key")
+		}
+		pub := ed25519.PublicKey(x)
+		if !j.isPrivate() {
+			return pub, nil, nil
+		}
+		seed, err := jwkDecode("d", j.D)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, nil, errors.New("invalid Ed25519 private key")
+		}
+		priv := ed25519.NewKeyFromSeed(seed)
+		if !bytes.Equal(priv.Public().(ed25519.PublicKey), pub) {
+			return nil, nil, errors.New("Ed25519 private key does not match public key")
+		}
+		return pub, priv, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %q", j.Kty)
+	}
+}
+
+// parseJWKs reads a single JWK or a JWK Set.
+func parseJWKs(data []byte) ([]jwk, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, ok := probe["keys"]; ok {
+		var set jwkSet
+		if err := json.Unmarshal(data, &set); err != nil {
+			return nil, err
+		}
+		if len(set.Keys) == 0 {
+			return nil, errors.New("JWK set contains no keys")
+		}
+		return set.Keys, nil
+	}
+	var j jwk
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	return []jwk{j}, nil
+}
+
+// Run the jwk subcommand
+func runJWK(args []string) error {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s jwk <export|import> [flags]\n", filepath.Base(os.Args[0]))
+	}
+	if len(args) == 0 {
+		usage()
+		return errors.New("missing jwk action")
+	}
+	switch args[0] {
+	case "export":
+		return runJWKExport(args[1:])
+	case "import":
+		return runJWKImport(args[1:])
+	default:
+		usage()
+		return fmt.Errorf("unknown jwk action %q", args[0])
+	}
+}
+
+// Run jwk export
+func runJWKExport(args []string) error {
+	fs := flag.NewFlagSet("jwk export", flag.ExitOnError)
+	out := fs.String("o", "", "output file (default: stdout)")
+	private := fs.Bool("private", false, "export a single private JWK instead of a public JWKS")
+	alg := fs.String("alg", "", "alg member (default: RS256, ES256/ES384/ES512 by curve, EdDSA)")
+	use := fs.String("use", "sig", "use member: sig or enc (empty to omit)")
+	passphrase := fs.String("passphrase", "", "passphrase source for encrypted private keys (default: prompt)")
+	force := fs.Bool("force", false, "overwrite the output file")
+	configPath := fs.String("config", "", "configuration file with the audit log (default: $"+configEnv+" or the user config directory)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s jwk export [flags] <key> [key...]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("no key files given")
+	}
+	if *private && fs.NArg() != 1 {
+		return errors.New("-private exports exactly one key")
+	}
+	if *use != "" && *use != "sig" && *use != "enc" {
+		return fmt.Errorf("invalid use %q (sig or enc)", *use)
+	}
+	if *out != "" && !*force && fileExists(*out) {
+		return fmt.Errorf("%s already exists (use -force to overwrite)", *out)
+	}
+
+	var set jwkSet
+	var sources []*decodedKey
+	for _, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		k, err := decodeKey(data, func() ([]byte, error) {
+			return passphraseOrPrompt(*passphrase, fmt.Sprintf("Enter passphrase for %s: ", path))
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if *private && k.priv == nil {
+			return fmt.Errorf("%s: -private requires a private key", path)
+		}
+
+		var priv interface{}
+		if *private {
+			priv = k.priv
+		}
+		j, err := newJWK(k.pub, priv)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := j.setAlg(*alg); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if j.Kty == "OKP" && *use == "enc" {
+			return fmt.Errorf("%s: Ed25519 keys can only be used for signatures", path)
+		}
+		j.Use = *use
+		if j.Kid, err = j.thumbprint(); err != nil {
+			return err
+		}
+		set.Keys = append(set.Keys, *j)
+		sources = append(sources, k)
+	}
+
+	var doc interface{} = set
+	perm := os.FileMode(0o644)
+	if *private {
+		doc, perm = set.Keys[0], 0o600
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := writeFileAtomic(*out, data, perm); err != nil {
+		return err
+	}
+	for i, k := range sources {
+		if err := logConversion(config, fs.Arg(i), k.pub, *out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run jwk import
+func runJWKImport(args []string) error {
+	fs := flag.NewFlagSet("jwk import", flag.ExitOnError)
+	in := fs.String("i", "", "JWK or JWKS file to import")
+	out := fs.String("f", "", "output private key file; public-only keys are written to <f>.pub")
+	kid := fs.String("kid", "", "key to import from a JWKS (required when it holds several keys)")
+	comment := fs.String("C", "", "comment for the SSH public key (default: kid)")
+	newPassphrase := fs.String("new-passphrase", "", "passphrase source to encrypt the imported private key (OpenSSH format)")
+	force := fs.Bool("force", false, "overwrite existing files")
+	configPath := fs.String("config", "", "configuration file with the audit log (default: $"+configEnv+" or the user config directory)")
+	fs.Parse(args)
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if *in == "" || *out == "" {
+		fs.Usage()
+		return errors.New("-i and -f are required")
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKs(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *in, err)
+	}
+	var selected *jwk
+	for i := range keys {
+		if *kid == "" || keys[i].Kid == *kid {
+			if selected != nil {
+				return fmt.Errorf("%s holds several keys; choose one with -kid", *in)
+			}
+			selected = &keys[i]
+		}
+	}
+	if selected == nil {
+		return fmt.Errorf("no key with kid %q in %s", *kid, *in)
+	}
+
+	pub, priv, err := selected.key()
+	if err != nil {
+		return fmt.Errorf("%s: %w", *in, err)
+	}
+	if *comment == "" {
+		*comment = selected.Kid
+	}
+
+	if priv == nil {
+		if !*force && fileExists(*out+".pub") {
+			return fmt.Errorf("%s.pub already exists (use -force to overwrite)", *out)
+		}
+		line, err := encodeKey(&decodedKey{pub: pub}, formatSSH, nil, 0, *comment)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(*out+".pub", line, 0o644); err != nil {
+			return err
+		}
+		if err := logConversion(config, *in, pub, *out+".pub"); err != nil {
+			return err
+		}
+		fmt.Printf("Public key saved to %s.pub (permissions 0644)\n", *out)
+		return nil
+	}
+
+	if !*force && (fileExists(*out) || fileExists(*out+".pub")) {
+		return fmt.Errorf("%s or %s.pub already exists (use -force to overwrite)", *out, *out)
+	}
+	newPass, err := resolvePassphrase(*newPassphrase)
+	if err != nil {
+		return err
+	}
+	if err := writeImportedKey(priv, *comment, *out, newPass); err != nil {
+		return err
+	}
+	if err := logConversion(config, *in, pub, *out, *out+".pub"); err != nil {
+		return err
+	}
+	fmt.Printf("Private key saved to %s (permissions 0600)\n", *out)
+	fmt.Printf("Public key saved to %s.pub (permissions 0644)\n", *out)
+	return nil
+}
@@ -0,0 +1,89 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : keyfile.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 13:52:19
+ * Description  : Reading existing private and public key files
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// loadPrivateKey reads a private key file written by this tool or by
+// ssh-keygen. Encrypted keys are decrypted with the passphrase from source,
+// or from a terminal prompt when source is empty.
+func loadPrivateKey(path, passphraseSource string) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, err := ssh.ParseRawPrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		passphrase, perr := passphraseOrPrompt(passphraseSource, fmt.Sprintf("Enter passphrase for %s: ", path))
+		if perr != nil {
+			return nil, perr
+		}
+		priv, err = ssh.ParseRawPrivateKeyWithPassphrase(data, passphrase)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return normalizePrivateKey(priv), nil
+}
+
+// normalizePrivateKey converts the *ed25519.PrivateKey returned for OpenSSH
+// files to the ed25519.PrivateKey value used everywhere else in this tool.
+func normalizePrivateKey(priv interface{}) interface{} {
+	if k, ok := priv.(*ed25519.PrivateKey); ok {
+		return *k
+	}
+	return priv
+}
+
+// privateKeyAlgorithm returns the algorithm constant for a private key.
+func privateKeyAlgorithm(priv interface{}) (string, error) {
+	switch priv.(type) {
+	case *rsa.PrivateKey:
+		return AlgorithmRSA, nil
+	case ed25519.PrivateKey:
+		return AlgorithmED25519, nil
+	case *ecdsa.PrivateKey:
+		return AlgorithmECDSA, nil
+	default:
+		return "", fmt.Errorf("unsupported private key type %T", priv)
+	}
+}
+
+// loadPublicKey reads the first key of an authorized_keys style file.
+func loadPublicKey(path string) (ssh.PublicKey, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	pub, comment, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", path, err)
+	}
+	return pub, comment, nil
+}
@@ -0,0 +1,304 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : keyscan.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 22:05:17
+ * Description  : Collecting and verifying the host keys of a running server
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Host key algorithm groups, one connection each. RSA keys are offered under
+// several signature algorithms but are a single key.
+var keyscanAlgorithms = map[string][]string{
+	ssh.KeyAlgoED25519:  {ssh.KeyAlgoED25519},
+	ssh.KeyAlgoECDSA256: {ssh.KeyAlgoECDSA256},
+	ssh.KeyAlgoECDSA384: {ssh.KeyAlgoECDSA384},
+	ssh.KeyAlgoECDSA521: {ssh.KeyAlgoECDSA521},
+	ssh.KeyAlgoRSA:      {ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA},
+}
+
+// Order in which host keys are requested and printed
+var keyscanOrder = []string{ssh.KeyAlgoED25519, ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521, ssh.KeyAlgoRSA}
+
+// Stops the handshake once the host key has been seen
+var errHostKeyCollected = errors.New("host key collected")
+
+// parseKeyscanTypes turns a -t list such as "rsa,ed25519" into key types.
+func parseKeyscanTypes(list string) ([]string, error) {
+	if list == "" {
+		return keyscanOrder, nil
+	}
+	var types []string
+	add := func(t string) {
+		if !containsString(types, t) {
+			types = append(types, t)
+		}
+	}
+	for _, name := range strings.Split(list, ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case "rsa":
+			add(ssh.KeyAlgoRSA)
+		case "ecdsa":
+			add(ssh.KeyAlgoECDSA256)
+			add(ssh.KeyAlgoECDSA384)
+			add(ssh.KeyAlgoECDSA521)
+		case "ed25519":
+			add(ssh.KeyAlgoED25519)
+		default:
+			if _, ok := keyscanAlgorithms[name]; !ok {
+				return nil, fmt.Errorf("unknown key type %q (rsa, ecdsa, ed25519 or an SSH key type)", name)
+			}
+			add(name)
+		}
+	}
+	return types, nil
+}
+
+// fetchHostKey performs a key exchange offering only the given host key
+// algorithms and returns the key the server presents, or nil when the server
+// has no key of that type.
+func fetchHostKey(addr string, algorithms []string, timeout time.Duration) (ssh.PublicKey, net.Addr, error) {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var key ssh.PublicKey
+	var remote net.Addr
+	config := &ssh.ClientConfig{
+		User:              "keyscan",
+		HostKeyAlgorithms: algorithms,
+		HostKeyCallback: func(hostname string, r net.Addr, k ssh.PublicKey) error {
+			key, remote = k, r
+			return errHostKeyCollected
+		},
+	}
+	_, _, _, err = ssh.NewClientConn(conn, addr, config)
+	if key != nil {
+		return key, remote, nil
+	}
+	var negotiation *ssh.AlgorithmNegotiationError
+	if errors.As(err, &negotiation) && negotiation.What == "host key" {
+		return nil, nil, nil
+	}
+	return nil, nil, err
+}
+
+// scanHostKeys collects the host keys a server offers for each key type.
+func scanHostKeys(addr string, types []string, timeout time.Duration) ([]ssh.PublicKey, net.Addr, error) {
+	var keys []ssh.PublicKey
+	var remote net.Addr
+	for _, t := range types {
+		key, r, err := fetchHostKey(addr, keyscanAlgorithms[t], timeout)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", addr, err)
+		}
+		if key != nil {
+			keys = append(keys, key)
+			remote = r
+		}
+	}
+	return keys, remote, nil
+}
+
+// compareHostKeys checks the offered keys against expected public keys, one
+// per key type: a different key of the same type, or an expected type the
+// server does not offer, is a mismatch.
+func compareHostKeys(offered, expected []ssh.PublicKey) []string {
+	var mismatches []string
+	for _, want := range expected {
+		found := false
+		for _, got := range offered {
+			if got.Type() != want.Type() {
+				continue
+			}
+			found = true
+			if !keysEqual(got, want) {
+				mismatches = append(mismatches, fmt.Sprintf("%s: server presents %s, expected %s",
+					want.Type(), ssh.FingerprintSHA256(got), ssh.FingerprintSHA256(want)))
+			}
+		}
+		if !found {
+			mismatches = append(mismatches, fmt.Sprintf("%s: server does not offer %s", want.Type(), ssh.FingerprintSHA256(want)))
+		}
+	}
+	return mismatches
+}
+
+// checkKnownHosts verifies the offered keys with a known_hosts callback. Like
+// OpenSSH, only entries of the same key type count as a mismatch; keys with no
+// entry of their type are reported as unknown.
+func checkKnownHosts(callback ssh.HostKeyCallback, addr string, remote net.Addr, offered []ssh.PublicKey) (mismatches, unknown []string) {
+	for _, key := range offered {
+		err := callback(addr, remote, key)
+		var keyErr *knownhosts.KeyError
+		var revoked *knownhosts.RevokedError
+		switch {
+		case err == nil:
+		case errors.As(err, &revoked):
+			mismatches = append(mismatches, fmt.Sprintf("%s: %s is revoked (%s:%d)",
+				key.Type(), ssh.FingerprintSHA256(key), revoked.Revoked.Filename, revoked.Revoked.Line))
+		case errors.As(err, &keyErr):
+			var want []string
+			for _, known := range keyErr.Want {
+				if known.Key.Type() == key.Type() {
+					want = append(want, fmt.Sprintf("%s (%s:%d)", ssh.FingerprintSHA256(known.Key), known.Filename, known.Line))
+				}
+			}
+			if len(want) == 0 {
+				unknown = append(unknown, fmt.Sprintf("%s: %s is not in known_hosts", key.Type(), ssh.FingerprintSHA256(key)))
+			} else {
+				mismatches = append(mismatches, fmt.Sprintf("%s: server presents %s, known_hosts has %s",
+					key.Type(), ssh.FingerprintSHA256(key), strings.Join(want, ", ")))
+			}
+		default:
+			mismatches = append(mismatches, fmt.Sprintf("%s: %v", key.Type(), err))
+		}
+	}
+	return mismatches, unknown
+}
+
+// readExpectedHostKeys loads the public keys of -pub arguments, which may be
+// glob patterns such as /etc/ssh/ssh_host_*_key.pub.
+func readExpectedHostKeys(patterns []string) ([]ssh.PublicKey, error) {
+	var keys []ssh.PublicKey
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("%s: no such file", pattern)
+		}
+		for _, path := range paths {
+			pub, _, err := loadPublicKey(path)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, pub)
+		}
+	}
+	return keys, nil
+}
+
+// Run the keyscan subcommand
+func runKeyscan(args []string) error {
+	fs := flag.NewFlagSet("keyscan", flag.ExitOnError)
+	port := fs.Int("p", 22, "port used when a host has none")
+	types := fs.String("t", "", "key types to request: rsa, ecdsa, ed25519 or SSH key types, comma separated (default: all)")
+	var pubFiles stringList
+	fs.Var(&pubFiles, "pub", "expected host public key file or glob, e.g. '/etc/ssh/ssh_host_*_key.pub' (repeatable)")
+	knownHosts := fs.String("known-hosts", "", "known_hosts file to verify the offered keys against")
+	hash := fs.Bool("H", false, "hash host names in the emitted known_hosts lines")
+	out := fs.String("o", "", "write the known_hosts lines to this file instead of stdout")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout per connection")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s keyscan [flags] host[:port]...\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("at least one host is required")
+	}
+
+	keyTypes, err := parseKeyscanTypes(*types)
+	if err != nil {
+		return err
+	}
+	expected, err := readExpectedHostKeys(pubFiles)
+	if err != nil {
+		return err
+	}
+	var callback ssh.HostKeyCallback
+	if *knownHosts != "" {
+		if callback, err = knownhosts.New(*knownHosts); err != nil {
+			return fmt.Errorf("loading known_hosts: %w", err)
+		}
+	}
+
+	var lines strings.Builder
+	problems := 0
+	for _, dest := range fs.Args() {
+		_, addr, err := parseSSHDestination(dest, "keyscan", *port)
+		if err != nil {
+			return err
+		}
+		keys, remote, err := scanHostKeys(addr, keyTypes, *timeout)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			fmt.Fprintf(os.Stderr, "%s: no host keys of the requested types\n", addr)
+			problems++
+			continue
+		}
+
+		host := knownhosts.Normalize(addr)
+		for _, key := range keys {
+			fmt.Fprintf(os.Stderr, "# %s %s %s\n", addr, key.Type(), ssh.FingerprintSHA256(key))
+			name := host
+			if *hash {
+				name = knownhosts.HashHostname(host)
+			}
+			lines.WriteString(knownhosts.Line([]string{name}, key) + "\n")
+		}
+
+		mismatches := compareHostKeys(keys, expected)
+		var unknown []string
+		if callback != nil {
+			m, u := checkKnownHosts(callback, addr, remote, keys)
+			mismatches, unknown = append(mismatches, m...), u
+		}
+		for _, m := range mismatches {
+			fmt.Fprintf(os.Stderr, "%s %s: %s\n", errorStyle.Render("mismatch:"), addr, m)
+		}
+		for _, u := range unknown {
+			fmt.Fprintf(os.Stderr, "%s %s: %s\n", warningStyle.Render("unknown:"), addr, u)
+		}
+		if (len(expected) > 0 || callback != nil) && len(mismatches) == 0 && len(unknown) == 0 {
+			fmt.Fprintf(os.Stderr, "%s %s: offered host keys match\n", successStyle.Render("ok:"), addr)
+		}
+		problems += len(mismatches)
+	}
+
+	if *out != "" {
+		if err := writeFileAtomic(*out, []byte(lines.String()), 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "known_hosts lines written to %s\n", *out)
+	} else {
+		fmt.Print(lines.String())
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d host key problems found", problems)
+	}
+	return nil
+}
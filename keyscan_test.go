@@ -0,0 +1,149 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : keyscan_test.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 23:02:44
+ * Description  : Tests for scanning and comparing server host keys
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// newTestSigner wraps a freshly generated private key.
+func newTestSigner(t *testing.T, priv interface{}) ssh.Signer {
+	t.Helper()
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// startHostKeyServer runs an SSH server presenting the given host keys and
+// returns its address.
+func startHostKeyServer(t *testing.T, hostKeys ...ssh.Signer) string {
+	t.Helper()
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	for _, k := range hostKeys {
+		config.AddHostKey(k)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if sconn, chans, reqs, err := ssh.NewServerConn(conn, config); err == nil {
+					go ssh.DiscardRequests(reqs)
+					for ch := range chans {
+						ch.Reject(ssh.Prohibited, "no channels")
+					}
+					sconn.Close()
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestScanAndCompareHostKeys(t *testing.T) {
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, rsaKey := newTestSigner(t, edPriv), newTestSigner(t, rsaPriv)
+	addr := startHostKeyServer(t, edKey, rsaKey)
+
+	offered, remote, err := scanHostKeys(addr, keyscanOrder, 5*time.Second)
+	if err != nil {
+		t.Fatalf("scanHostKeys: %v", err)
+	}
+	if remote == nil {
+		t.Error("no remote address returned")
+	}
+	if len(offered) != 2 || !keysEqual(offered[0], edKey.PublicKey()) || !keysEqual(offered[1], rsaKey.PublicKey()) {
+		var types []string
+		for _, k := range offered {
+			types = append(types, k.Type())
+		}
+		t.Fatalf("offered %v, want ed25519 and rsa host keys in that order", types)
+	}
+
+	// Both expected keys match
+	if m := compareHostKeys(offered, []ssh.PublicKey{edKey.PublicKey(), rsaKey.PublicKey()}); len(m) != 0 {
+		t.Errorf("matching keys reported %q", m)
+	}
+
+	// A different ed25519 key is a mismatch naming both fingerprints
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	other := newTestSigner(t, otherPriv).PublicKey()
+	m := compareHostKeys(offered, []ssh.PublicKey{other, rsaKey.PublicKey()})
+	if len(m) != 1 || !strings.Contains(m[0], "server presents "+ssh.FingerprintSHA256(edKey.PublicKey())) ||
+		!strings.Contains(m[0], "expected "+ssh.FingerprintSHA256(other)) {
+		t.Errorf("mismatch reported %q", m)
+	}
+
+	// An expected ECDSA key the server does not have is not offered
+	ecPriv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey := newTestSigner(t, ecPriv).PublicKey()
+	m = compareHostKeys(offered, []ssh.PublicKey{ecKey})
+	if len(m) != 1 || !strings.Contains(m[0], "server does not offer "+ssh.FingerprintSHA256(ecKey)) {
+		t.Errorf("missing key reported %q", m)
+	}
+}
+
+func TestScanHostKeysSelectedTypes(t *testing.T) {
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startHostKeyServer(t, newTestSigner(t, edPriv))
+
+	types, err := parseKeyscanTypes("rsa,ecdsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	offered, _, err := scanHostKeys(addr, types, 5*time.Second)
+	if err != nil {
+		t.Fatalf("scanHostKeys: %v", err)
+	}
+	if len(offered) != 0 {
+		t.Fatalf("server with only ed25519 offered %d rsa/ecdsa keys", len(offered))
+	}
+}
@@ -32,6 +32,7 @@ import (
 	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"golang.org/x/crypto/ed25519"
@@ -109,6 +110,9 @@ type keyGenStartMsg struct{}
 type keyGenCompleteMsg struct {
 	privatePath, publicPath string
 	comment                 string
+	selfTest                string   // summary of the passed self-test
+	hooks, hookWarnings     []string // results and tolerated failures of the generation hooks
+	reload, reloadErr       string   // result of the reload hook, if configured
 }
 type keyGenErrorMsg struct {
 	err error
@@ -124,7 +128,9 @@ type keyGenStep2CompleteMsg struct {
 type keyGenStep3CompleteMsg struct {
 	pubKey []byte
 }
-type keyGenStep4CompleteMsg struct{}
+type keyGenStep4CompleteMsg struct {
+	privBackup keyFileBackup // previous private key, restored on failure
+}
 
 // Confirmation messages
 type confirmOverwriteMsg struct {
@@ -160,6 +166,29 @@ type model struct {
 	priv         interface{} // Can be *rsa.PrivateKey, ed25519.PrivateKey, or *ecdsa.PrivateKey
 	privPEM      []byte
 	pubKey       []byte
+	privBackup   keyFileBackup // previous private key file, for rolling back
+	selfTest     string        // self-test summary shown on the success screen
+	config       *appConfig    // settings from the configuration file
+	hooks        []string      // generation hook results shown on the success screen
+	hookWarnings []string      // generation hook failures kept under the warn policy
+	reload       string        // reload hook result shown on the success screen
+	reloadErr    string        // reload hook failure shown on the success screen
+	// Multi-key mode
+	multiIdx      int           // Cursor in the combination list
+	multiSelected []bool        // Ticked combinations
+	multiJobs     []multiKeyJob // Keys being generated concurrently
+	multiReload   bool          // Reload hook running after the last job
+	// authorized_keys options step
+	optIdx     int                  // Focused option row
+	optToggles [3]bool              // restrict, port-forwarding, no-pty
+	optInputs  []textinput.Model    // permitopen, permitlisten, from, command, expiry-time
+	optError   string               // Validation error shown in the options step
+	keyOptions authorizedKeyOptions // Options applied to the public key line
+	// ssh-agent checkbox on the success screen
+	agentState    string // "", "adding", "added", "failed"
+	agentMessage  string
+	agentLifetime int  // index into agentLifetimes
+	agentConfirm  bool // require confirmation before each use
 }
 
 // generateRSAKey generates an RSA private key of the given bit size.
@@ -297,6 +326,131 @@ func publicKeySSHPublicKey(priv interface{}, algorithm, comment string) ([]byte,
 	return authorized, nil
 }
 
+// encodePrivateKeyWithPassphrase encodes a private key in the OpenSSH format,
+// encrypted with the given passphrase.
+func encodePrivateKeyWithPassphrase(priv interface{}, comment string, passphrase []byte) ([]byte, error) {
+	block, err := ssh.MarshalPrivateKeyWithPassphrase(priv, comment, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(block), nil
+}
+
+// parseAlgorithm maps a user supplied algorithm name (rsa, ed25519, ecdsa) to its constant.
+func parseAlgorithm(name string) (string, error) {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case AlgorithmRSA:
+		return AlgorithmRSA, nil
+	case AlgorithmED25519:
+		return AlgorithmED25519, nil
+	case AlgorithmECDSA:
+		return AlgorithmECDSA, nil
+	default:
+		return "", fmt.Errorf("unsupported algorithm: %s (supported: rsa, ed25519, ecdsa)", name)
+	}
+}
+
+// algorithmInfo returns the algorithm table entry for the given algorithm.
+func algorithmInfo(algorithm string) (AlgorithmInfo, bool) {
+	for _, alg := range algorithms {
+		if alg.Name == algorithm {
+			return alg, true
+		}
+	}
+	return AlgorithmInfo{}, false
+}
+
+// validateKeySize checks that bits is one of the supported sizes for the algorithm.
+func validateKeySize(algorithm string, bits int) error {
+	info, ok := algorithmInfo(algorithm)
+	if !ok {
+		return fmt.Errorf("unsupported algorithm: %s", algorithm)
+	}
+	for _, size := range info.KeySizes {
+		if size == bits {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported %s key size: %d (supported: %s)", algorithm, bits, strings.Trim(fmt.Sprint(info.KeySizes), "[]"))
+}
+
+// defaultKeyFileName returns the conventional private key file name for the algorithm.
+func defaultKeyFileName(algorithm string) string {
+	switch algorithm {
+	case AlgorithmED25519:
+		return "id_ed25519"
+	case AlgorithmECDSA:
+		return "id_ecdsa"
+	default:
+		return "id_rsa"
+	}
+}
+
+// generatePrivateKey generates a private key for the given algorithm and size.
+func generatePrivateKey(algorithm string, bits int) (interface{}, error) {
+	switch algorithm {
+	case AlgorithmRSA:
+		return generateRSAKey(bits)
+	case AlgorithmED25519:
+		return generateED25519Key()
+	case AlgorithmECDSA:
+		curve, err := getECDSACurve(bits)
+		if err != nil {
+			return nil, err
+		}
+		return generateECDSAKey(curve)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
+	}
+}
+
+// writeKeyPair writes the private and public key files. If the public key
+// cannot be written, the files saved in backups are put back.
+func writeKeyPair(privatePath, publicPath string, privPEM, pubKey []byte, backups ...keyFileBackup) error {
+	if err := writeFileAtomic(privatePath, privPEM, 0o600); err != nil {
+		return fmt.Errorf("writing private key: %w", err)
+	}
+	if err := writeFileAtomic(publicPath, pubKey, 0o644); err != nil {
+		return rollbackKeyFiles(fmt.Errorf("writing public key: %w", err), backups...)
+	}
+	return nil
+}
+
+// generateKeyPairFiles generates a new key and writes it to privatePath and
+// privatePath.pub. When passphrase is non-empty the private key is written in
+// the encrypted OpenSSH format, otherwise in PEM. backups are restored if the
+// pair cannot be written. It returns the public key.
+func generateKeyPairFiles(algorithm string, bits int, comment, privatePath string, passphrase []byte, backups ...keyFileBackup) (ssh.PublicKey, error) {
+	priv, err := generatePrivateKey(algorithm, bits)
+	if err != nil {
+		return nil, err
+	}
+
+	var privPEM []byte
+	if len(passphrase) > 0 {
+		privPEM, err = encodePrivateKeyWithPassphrase(priv, comment, passphrase)
+	} else {
+		privPEM, err = encodePrivateKeyToPEM(priv, algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := publicKeySSHPublicKey(priv, algorithm, comment)
+	if err != nil {
+		return nil, err
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeKeyPair(privatePath, privatePath+".pub", privPEM, pubKey, backups...); err != nil {
+		return nil, err
+	}
+	return pub, nil
+}
+
 func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
 	dir := filepath.Dir(path)
 	if dir == "" {
@@ -447,6 +601,10 @@ func keyGenerationStep3(priv interface{}, m model) tea.Cmd {
 		if err != nil {
 			return keyGenErrorMsg{err: err}
 		}
+		pubKey, err = applyAuthorizedKeyOptions(pubKey, m.keyOptions)
+		if err != nil {
+			return keyGenErrorMsg{err: err}
+		}
 		return keyGenStep3CompleteMsg{pubKey: pubKey}
 	}
 }
@@ -454,26 +612,54 @@ func keyGenerationStep3(priv interface{}, m model) tea.Cmd {
 func keyGenerationStep4(privPEM []byte, m model) tea.Cmd {
 	return func() tea.Msg {
 		time.Sleep(400 * time.Millisecond)
+		backup, err := backupKeyFile(m.privatePath)
+		if err != nil {
+			return keyGenErrorMsg{err: err}
+		}
 		if err := writeFileAtomic(m.privatePath, privPEM, 0o600); err != nil {
 			return keyGenErrorMsg{err: err}
 		}
-		return keyGenStep4CompleteMsg{}
+		return keyGenStep4CompleteMsg{privBackup: backup}
 	}
 }
 
 func keyGenerationStep5(pubKey []byte, m model) tea.Cmd {
 	return func() tea.Msg {
 		time.Sleep(400 * time.Millisecond)
+		backup, err := backupKeyFile(m.publicPath)
+		if err != nil {
+			return keyGenErrorMsg{err: rollbackKeyFiles(err, m.privBackup)}
+		}
 		if err := writeFileAtomic(m.publicPath, pubKey, 0o644); err != nil {
-			// try to remove private if public write fails
-			_ = os.Remove(m.privatePath)
-			return keyGenErrorMsg{err: err}
+			// put the previous private key back if public write fails
+			return keyGenErrorMsg{err: rollbackKeyFiles(err, m.privBackup)}
 		}
-		return keyGenCompleteMsg{
+		// Check the files on disk form a working pair
+		selfTest, err := selfTestKeyPair(m.privatePath, m.publicPath, nil)
+		if err != nil {
+			return keyGenErrorMsg{err: rollbackKeyFiles(err, m.privBackup, backup)}
+		}
+		done := keyGenCompleteMsg{
 			privatePath: m.privatePath,
 			publicPath:  m.publicPath,
 			comment:     m.comment,
+			selfTest:    selfTest,
 		}
+		if m.config != nil {
+			done.hooks, done.hookWarnings, err = runWrittenKeyHooks(m.config, m.privatePath, m.publicPath, m.privBackup, backup)
+			if err != nil {
+				return keyGenErrorMsg{err: err}
+			}
+		}
+		// The keys stay in place if the reload fails; it is only reported
+		if m.config != nil && m.config.Reload.enabled() {
+			if result, err := runReloadHook(m.config.Reload); err != nil {
+				done.reloadErr = err.Error()
+			} else {
+				done.reload = result
+			}
+		}
+		return done
 	}
 }
 
@@ -535,21 +721,22 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.privatePath = "id_rsa"
 					m.publicPath = "id_rsa.pub"
 				}
-				// Check if files exist
-				filesExist, _ := checkExistingFiles(m.privatePath, m.publicPath)
-				if filesExist {
-					m.state = "confirm"
-				} else {
-					m.state = "generating"
-					return m, tea.Batch(
-						keyGenerationStep1(m),
-						progressUpdateCmd(),
-					)
+				// Ask for authorized_keys options next
+				m.state = "authorized_options"
+				if m.optInputs == nil {
+					m.optInputs = newAuthorizedOptionInputs()
 				}
+				return m.focusAuthorizedOption(), nil
+			case "m", "M":
+				m.state = "multi_selection"
 				return m, nil
 			case "q", "Q", "ctrl+c":
 				return m, tea.Quit
 			}
+		case "authorized_options":
+			return m.updateAuthorizedOptions(msg)
+		case "multi_selection", "multi_confirm", "multi_complete":
+			return m.updateMultiKeyInput(msg)
 		case "confirm":
 			switch msg.String() {
 			case "y", "Y":
@@ -561,6 +748,28 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, tea.Quit
 			}
 		case "complete":
+			canAdd := m.agentState == "" || m.agentState == "failed"
+			switch msg.String() {
+			case "a", "A":
+				if canAdd {
+					m.agentState = "adding"
+					return m, addKeyToAgentCmd(m.priv, m.comment, agentOptions{
+						lifetime: agentLifetimes[m.agentLifetime],
+						confirm:  m.agentConfirm,
+					})
+				}
+				return m, nil
+			case "l", "L":
+				if canAdd {
+					m.agentLifetime = (m.agentLifetime + 1) % len(agentLifetimes)
+				}
+				return m, nil
+			case "c", "C":
+				if canAdd {
+					m.agentConfirm = !m.agentConfirm
+				}
+				return m, nil
+			}
 			// Wait for any key to exit
 			return m, tea.Quit
 		case "error":
@@ -576,6 +785,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.progress.Width > maxWidth {
 			m.progress.Width = maxWidth
 		}
+		for i := range m.multiJobs {
+			m.multiJobs[i].progress.Width = m.progress.Width
+		}
 		return m, nil
 
 	case confirmOverwriteMsg:
@@ -632,7 +844,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		)
 
 	case keyGenStep4CompleteMsg:
-		m.message = "Private key written, writing public key..."
+		m.privBackup = msg.privBackup
+		m.message = "Private key written, writing and testing public key..."
 		m.progress.SetPercent(0.8)
 		return m, tea.Batch(
 			keyGenerationStep5(m.pubKey, m),
@@ -645,6 +858,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.privatePath = msg.privatePath
 		m.publicPath = msg.publicPath
 		m.comment = msg.comment
+		m.selfTest = msg.selfTest
+		m.hooks, m.hookWarnings = msg.hooks, msg.hookWarnings
+		m.reload, m.reloadErr = msg.reload, msg.reloadErr
 		// Set progress to 100%
 		cmd := m.progress.SetPercent(1.0)
 		// Wait 2 seconds before showing success message
@@ -661,6 +877,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		// Stop progress updates
 		return m, nil
 
+	case agentAddedMsg:
+		if msg.err != nil {
+			m.agentState = "failed"
+			m.agentMessage = msg.err.Error()
+		} else {
+			m.agentState = "added"
+			m.agentMessage = ""
+		}
+		return m, nil
+
+	case multiKeyGeneratedMsg, multiKeyWrittenMsg, multiKeyErrorMsg, multiKeyReloadedMsg:
+		return m.updateMultiKeyProgress(msg)
+
 	case keyGenErrorMsg:
 		m.state = "error"
 		m.message = fmt.Sprintf("Error: %v", msg.err)
@@ -700,9 +929,15 @@ func (m model) View() string {
 			view += pad + prefix + fmt.Sprintf(" %s - %s", alg.Name, alg.Description) + "\n"
 		}
 
-		view += "\n" + pad + helpStyle("Use ↑/↓ or j/k to navigate, Enter to select, q to quit")
+		view += "\n" + pad + helpStyle("Use ↑/↓ or j/k to navigate, Enter to select, m for multi-key mode, q to quit")
 		return view
 
+	case "authorized_options":
+		return m.viewAuthorizedOptions()
+
+	case "multi_selection", "multi_confirm", "multi_generating", "multi_complete":
+		return m.viewMultiKey()
+
 	case "confirm":
 		return "\n" +
 			pad + titleStyle.Render(AppTitle) + "\n\n" +
@@ -747,9 +982,37 @@ func (m model) View() string {
 			pad + successStyle.Render("✅ Key generation completed successfully!") + "\n\n" +
 			pad + fmt.Sprintf("Private key saved to: %s (permissions 0600)", m.privatePath) + "\n" +
 			pad + fmt.Sprintf("Public key saved to:  %s (permissions 0644)", m.publicPath) + "\n"
+		if m.selfTest != "" {
+			view += pad + successStyle.Render("Self-test passed: ") + m.selfTest + "\n"
+		}
+		for _, h := range m.hooks {
+			view += pad + successStyle.Render("Hook: ") + h + "\n"
+		}
+		for _, w := range m.hookWarnings {
+			view += pad + warningStyle.Render("Hook failed: ") + w + "\n"
+		}
+		if m.reload != "" {
+			view += pad + successStyle.Render("Server reloaded: ") + m.reload + "\n"
+		}
+		if m.reloadErr != "" {
+			view += pad + errorStyle.Render("Server reload failed: ") + m.reloadErr + "\n"
+		}
 		if m.comment != "" {
 			view += pad + fmt.Sprintf("Key comment: %s", m.comment) + "\n\n"
 		}
+		switch m.agentState {
+		case "adding":
+			view += "\n" + pad + "[ ] Adding key to ssh-agent..." + "\n"
+		case "added":
+			view += "\n" + pad + successStyle.Render("[x] Key added to ssh-agent") + "\n"
+		case "failed":
+			view += "\n" + pad + "[ ] Add key to ssh-agent (press a)" + "\n" +
+				m.agentConstraintsView(pad) +
+				pad + errorStyle.Render(m.agentMessage) + "\n"
+		default:
+			view += "\n" + pad + "[ ] Add key to ssh-agent (press a)" + "\n" +
+				m.agentConstraintsView(pad)
+		}
 		return view + "\n" +
 			pad + helpStyle("Press any key to exit")
 
@@ -765,6 +1028,21 @@ func (m model) View() string {
 	}
 }
 
+// startOrConfirmGeneration asks for overwrite confirmation when the key files
+// exist, otherwise starts generating.
+func (m model) startOrConfirmGeneration() (tea.Model, tea.Cmd) {
+	filesExist, _ := checkExistingFiles(m.privatePath, m.publicPath)
+	if filesExist {
+		m.state = "confirm"
+		return m, nil
+	}
+	m.state = "generating"
+	return m, tea.Batch(
+		keyGenerationStep1(m),
+		progressUpdateCmd(),
+	)
+}
+
 // Check if files exist and need overwrite confirmation
 func checkExistingFiles(privatePath, publicPath string) (bool, error) {
 	privateExists := false
@@ -787,8 +1065,91 @@ func runNonInteractive() {
 	out := flag.String("f", "id_rsa", "output filename for private key (public will be <f>.pub)")
 	comment := flag.String("C", "", "key comment (e.g., user@host)")
 	force := flag.Bool("force", false, "overwrite existing files")
+	addAgent := flag.Bool("agent", false, "add the new private key to the ssh-agent at SSH_AUTH_SOCK")
+	agentLifetime := flag.Duration("agent-lifetime", 0, "lifetime of the key in the ssh-agent (e.g. 8h; 0 = unlimited)")
+	agentConfirm := flag.Bool("agent-confirm", false, "require confirmation before each use of the key in the ssh-agent")
+	var keyOptions authorizedKeyOptions
+	flag.Func("O", "authorized_keys option for the public key, repeatable (restrict, port-forwarding, no-pty, permitopen=host:port, permitlisten=[host:]port, from=patterns, command=cmd, expiry-time=YYYYMMDD)", keyOptions.set)
+	exportKey := flag.Bool("e", false, "print the public key of -f in RFC 4716 (SSH2) format instead of generating a key")
+	importKey := flag.Bool("i", false, "print the RFC 4716 (SSH2) public keys in -f as authorized_keys lines instead of generating a key")
+	subject := flag.String("subject", "", "Subject header for -e")
+	changePassphrase := flag.Bool("p", false, "change the passphrase or encryption of the existing key -f instead of generating a key")
+	changeComment := flag.Bool("c", false, "change the comment of the existing key -f (and its .pub) to -C instead of generating a key")
+	oldPassphrase := flag.String("P", "", "old passphrase source for -p/-c (env:, file:, pass:; default: prompt)")
+	newPassphrase := flag.String("N", "", "new passphrase source for -p (pass: for none; default: prompt)")
+	kdfRounds := flag.Int("a", 0, "bcrypt KDF rounds when encrypting with -p (default: keep, or 16)")
+	cipherName := flag.String("Z", "", "cipher when encrypting with -p: aes256-ctr or aes256-cbc (default: keep, or aes256-ctr)")
+	backup := flag.Bool("backup", false, "keep the previous files as .bak when using -p/-c")
+	configPath := flag.String("config", "", "configuration file (default: $"+configEnv+" or the user config directory)")
+	reloadSignal := flag.String("reload-signal", "", "signal sent to the server after writing the keys (default HUP)")
+	reloadPidFile := flag.String("reload-pid-file", "", "signal the process whose id is in this file after writing the keys")
+	reloadProcess := flag.String("reload-process", "", "signal the oldest process with this name after writing the keys")
+	reloadCommand := flag.String("reload-command", "", "run this shell command after writing the keys, e.g. 'systemctl reload sshd'")
+	noReload := flag.Bool("no-reload", false, "skip the reload hook of the configuration file")
 	flag.Parse()
 
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+
+	// In-place changes of an existing key
+	if *changePassphrase || *changeComment {
+		err := rekeyPrivateKey(rekeyOptions{
+			path:             *out,
+			oldPassphrase:    *oldPassphrase,
+			changePassphrase: *changePassphrase,
+			newPassphrase:    *newPassphrase,
+			changeComment:    *changeComment,
+			comment:          *comment,
+			cipherName:       *cipherName,
+			rounds:           *kdfRounds,
+			backup:           *backup,
+			config:           config,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// RFC 4716 conversion
+	if *exportKey || *importKey {
+		var err error
+		if *exportKey {
+			err = exportRFC4716(*out, *subject, *comment)
+		} else {
+			err = importRFC4716(*out)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := keyOptions.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+
+	// Reload flags replace the reload section of the configuration file
+	reload := config.Reload
+	if *reloadPidFile != "" || *reloadProcess != "" || *reloadCommand != "" {
+		reload = reloadConfig{PidFile: *reloadPidFile, Process: *reloadProcess, Command: *reloadCommand, Timeout: reload.Timeout}
+	}
+	if *reloadSignal != "" {
+		reload.Signal = *reloadSignal
+	}
+	if *noReload {
+		reload = reloadConfig{}
+	} else if err := reload.validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(2)
+	}
+
 	privatePath := *out
 	publicPath := privatePath + ".pub"
 
@@ -826,6 +1187,22 @@ func runNonInteractive() {
 		fmt.Fprintf(os.Stderr, "error creating ssh public key: %v\n", err)
 		os.Exit(1)
 	}
+	pubKey, err = applyAuthorizedKeyOptions(pubKey, keyOptions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error applying authorized_keys options: %v\n", err)
+		os.Exit(1)
+	}
+
+	privBackup, err := backupKeyFile(privatePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading existing private key: %v\n", err)
+		os.Exit(1)
+	}
+	pubBackup, err := backupKeyFile(publicPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error reading existing public key: %v\n", err)
+		os.Exit(1)
+	}
 
 	// write private with 0600
 	if err := writeFileAtomic(privatePath, privPEM, 0o600); err != nil {
@@ -835,17 +1212,57 @@ func runNonInteractive() {
 
 	// write public with 0644
 	if err := writeFileAtomic(publicPath, pubKey, 0o644); err != nil {
-		// try to remove private if public write fails
-		_ = os.Remove(privatePath)
-		fmt.Fprintf(os.Stderr, "error writing public key: %v\n", err)
+		// put the previous private key back if public write fails
+		fmt.Fprintf(os.Stderr, "error writing public key: %v\n", rollbackKeyFiles(err, privBackup))
+		os.Exit(1)
+	}
+
+	// check the files on disk form a working pair
+	selfTest, err := selfTestKeyPair(privatePath, publicPath, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", rollbackKeyFiles(err, privBackup, pubBackup))
+		os.Exit(1)
+	}
+
+	// record the new key in the audit log, then report it to the hooks
+	hooks, hookWarnings, err := runWrittenKeyHooks(config, privatePath, publicPath, privBackup, pubBackup)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
 
 	fmt.Printf("Private key saved to %s (permissions 0600)\n", privatePath)
 	fmt.Printf("Public key saved to %s (permissions 0644)\n", publicPath)
+	fmt.Printf("Self-test passed: %s\n", selfTest)
+	for _, h := range hooks {
+		fmt.Printf("Hook: %s\n", h)
+	}
+	for _, w := range hookWarnings {
+		fmt.Fprintf(os.Stderr, "warning: hook failed: %s\n", w)
+	}
 	if *comment != "" {
 		fmt.Printf("Key comment: %s\n", *comment)
 	}
+
+	// load into ssh-agent
+	if *addAgent {
+		opts := agentOptions{lifetime: *agentLifetime, confirm: *agentConfirm}
+		if err := addKeyToAgent(priv, *comment, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Key added to ssh-agent")
+	}
+
+	// let the server pick up the new keys
+	if reload.enabled() {
+		result, err := runReloadHook(reload)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: server reload failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Server reloaded: %s\n", result)
+	}
 }
 
 // Run in interactive mode (no command line arguments)
@@ -859,6 +1276,12 @@ func runInteractive() {
 		comment:     "",
 		force:       false,
 	}
+	config, err := loadConfig("")
+	if err != nil {
+		fmt.Println("Error reading configuration:", err)
+		os.Exit(1)
+	}
+	m.config = config
 
 	// Start the program
 	p := tea.NewProgram(m, tea.WithAltScreen())
@@ -868,9 +1291,61 @@ func runInteractive() {
 	}
 }
 
+// Subcommand available in non-interactive mode
+type command struct {
+	Name        string
+	Description string
+	Run         func(args []string) error
+}
+
+// commands returns the subcommands recognised as the first command line argument.
+func commands() []command {
+	return []command{
+		{Name: "batch", Description: "generate keys from a CSV/YAML/JSON manifest", Run: runBatch},
+		{Name: "copy-id", Description: "install a public key in a remote authorized_keys file", Run: runCopyID},
+		{Name: "authorized-keys", Description: "add, remove, list and lint authorized_keys entries", Run: runAuthorizedKeys},
+		{Name: "sign", Description: "sign files with an SSH key (SSHSIG, like ssh-keygen -Y sign)", Run: runSign},
+		{Name: "verify", Description: "verify an SSHSIG signature against an allowed_signers file", Run: runVerify},
+		{Name: "find-principals", Description: "list allowed_signers principals matching a signature", Run: runFindPrincipals},
+		{Name: "check-novalidate", Description: "check an SSHSIG signature without an allowed_signers file", Run: runCheckNoValidate},
+		{Name: "allowed-signers", Description: "add, remove and validate allowed_signers entries", Run: runAllowedSigners},
+		{Name: "ppk", Description: "export keys to PuTTY PPK v2/v3 and import PPK files", Run: runPPK},
+		{Name: "convert", Description: "convert keys between PKCS#1, SEC1, PKCS#8, OpenSSH, DER and SPKI", Run: runConvert},
+		{Name: "jwk", Description: "export keys as JWK/JWKS and import JWKs", Run: runJWK},
+		{Name: "csr", Description: "create a PKCS#10 certificate request for a key", Run: runCSR},
+		{Name: "cert", Description: "create a self-signed X.509 certificate for a key", Run: runCert},
+		{Name: "pkcs12", Description: "export an RSA or ECDSA key (and certificate) as a PKCS#12 bundle", Run: runPKCS12},
+		{Name: "scan", Description: "inventory private keys, public keys, certificates and authorized_keys files", Run: runScan},
+		{Name: "reuse", Description: "find keys reused across paths and hosts, and orphaned .pub files", Run: runReuse},
+		{Name: "drift", Description: "compare two key inventory snapshots and enforce a drift policy", Run: runDrift},
+		{Name: "audit", Description: "check keys for weak RSA sizes and exponents, Fermat, ROCA, shared primes and off-curve ECDSA points", Run: runAudit},
+		{Name: "keyscan", Description: "collect the host keys a running server offers and check them against .pub files or known_hosts", Run: runKeyscan},
+		{Name: "rotate", Description: "rotate a host key with an overlap period: start, publish, promote, retire", Run: runRotate},
+		{Name: "verify-log", Description: "check the hash chain of the key audit log", Run: runVerifyLog},
+	}
+}
+
+// findCommand returns the subcommand with the given name, or nil.
+func findCommand(name string) *command {
+	for _, cmd := range commands() {
+		if cmd.Name == name {
+			return &cmd
+		}
+	}
+	return nil
+}
+
 func main() {
 	// Check if any command line arguments were provided
 	if len(os.Args) > 1 {
+		// Dispatch subcommands
+		if cmd := findCommand(os.Args[1]); cmd != nil {
+			if err := cmd.Run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
 		// Run in non-interactive mode
 		runNonInteractive()
 	} else {
@@ -0,0 +1,409 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : multikey.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 10:02:17
+ * Description  : Interactive multi-key mode generating several keys concurrently
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/crypto/ssh"
+)
+
+// Algorithm and size combination offered in multi-key mode
+type keyCombo struct {
+	algorithm string
+	bits      int
+}
+
+// label returns a short human readable name for the combination.
+func (c keyCombo) label() string {
+	switch c.algorithm {
+	case AlgorithmRSA:
+		return fmt.Sprintf("RSA %d", c.bits)
+	case AlgorithmECDSA:
+		return fmt.Sprintf("ECDSA P-%d", c.bits)
+	default:
+		return c.algorithm
+	}
+}
+
+// One key being generated in multi-key mode
+type multiKeyJob struct {
+	combo       keyCombo
+	privatePath string
+	publicPath  string
+	progress    progress.Model
+	percent     float64
+	message     string
+	fingerprint string
+	warning     string // failed hooks under the warn policy
+	err         error
+	done        bool
+}
+
+// Multi-key messages
+type multiKeyGeneratedMsg struct {
+	index int
+	priv  interface{}
+}
+type multiKeyWrittenMsg struct {
+	index       int
+	fingerprint string
+	warning     string
+}
+type multiKeyErrorMsg struct {
+	index int
+	err   error
+}
+type multiKeyReloadedMsg struct {
+	reload, reloadErr string
+}
+
+// multiKeyCombos lists every algorithm/size combination in table order.
+func multiKeyCombos() []keyCombo {
+	var combos []keyCombo
+	for _, alg := range algorithms {
+		for _, size := range alg.KeySizes {
+			combos = append(combos, keyCombo{algorithm: alg.Name, bits: size})
+		}
+	}
+	return combos
+}
+
+// buildMultiKeyJobs creates a job per selected combination. Keys share the
+// conventional file name unless several sizes of one algorithm are selected,
+// in which case the size is appended (id_rsa_2048, id_rsa_4096, ...).
+func buildMultiKeyJobs(selected []keyCombo, width int) []multiKeyJob {
+	perAlgorithm := make(map[string]int)
+	for _, c := range selected {
+		perAlgorithm[c.algorithm]++
+	}
+
+	jobs := make([]multiKeyJob, 0, len(selected))
+	for _, c := range selected {
+		name := defaultKeyFileName(c.algorithm)
+		if perAlgorithm[c.algorithm] > 1 {
+			name = fmt.Sprintf("%s_%d", name, c.bits)
+		}
+		p := progress.New(progress.WithDefaultGradient())
+		if width > 0 {
+			p.Width = width
+		}
+		jobs = append(jobs, multiKeyJob{
+			combo:       c,
+			privatePath: name,
+			publicPath:  name + ".pub",
+			progress:    p,
+			message:     "Waiting...",
+		})
+	}
+	return jobs
+}
+
+// multiKeyGenerateCmd generates the private key for job index.
+func multiKeyGenerateCmd(index int, combo keyCombo) tea.Cmd {
+	return func() tea.Msg {
+		priv, err := generatePrivateKey(combo.algorithm, combo.bits)
+		if err != nil {
+			return multiKeyErrorMsg{index: index, err: err}
+		}
+		return multiKeyGeneratedMsg{index: index, priv: priv}
+	}
+}
+
+// multiKeyWriteCmd encodes, writes and self-tests both halves of the key for
+// job index, records it in the audit log and runs the generation hooks.
+func multiKeyWriteCmd(index int, job multiKeyJob, priv interface{}, comment string, config *appConfig) tea.Cmd {
+	return func() tea.Msg {
+		privPEM, err := encodePrivateKeyToPEM(priv, job.combo.algorithm)
+		if err != nil {
+			return multiKeyErrorMsg{index: index, err: err}
+		}
+		pubKey, err := publicKeySSHPublicKey(priv, job.combo.algorithm, comment)
+		if err != nil {
+			return multiKeyErrorMsg{index: index, err: err}
+		}
+		pub, _, _, _, err := ssh.ParseAuthorizedKey(pubKey)
+		if err != nil {
+			return multiKeyErrorMsg{index: index, err: err}
+		}
+		privBackup, err := backupKeyFile(job.privatePath)
+		if err != nil {
+			return multiKeyErrorMsg{index: index, err: err}
+		}
+		pubBackup, err := backupKeyFile(job.publicPath)
+		if err != nil {
+			return multiKeyErrorMsg{index: index, err: err}
+		}
+		if err := writeKeyPair(job.privatePath, job.publicPath, privPEM, pubKey, privBackup, pubBackup); err != nil {
+			return multiKeyErrorMsg{index: index, err: err}
+		}
+		// Check the files on disk form a working pair
+		if _, err := selfTestKeyPair(job.privatePath, job.publicPath, nil); err != nil {
+			return multiKeyErrorMsg{index: index, err: rollbackKeyFiles(err, privBackup, pubBackup)}
+		}
+		var warnings []string
+		if config != nil {
+			_, warnings, err = runWrittenKeyHooks(config, job.privatePath, job.publicPath, privBackup, pubBackup)
+		}
+		if err != nil {
+			return multiKeyErrorMsg{index: index, err: err}
+		}
+		return multiKeyWrittenMsg{index: index, fingerprint: ssh.FingerprintSHA256(pub), warning: strings.Join(warnings, "; ")}
+	}
+}
+
+// startMultiKeyGeneration starts every job concurrently.
+func (m model) startMultiKeyGeneration() (model, tea.Cmd) {
+	m.state = "multi_generating"
+	cmds := make([]tea.Cmd, 0, len(m.multiJobs))
+	for i := range m.multiJobs {
+		m.multiJobs[i].percent = 0.1
+		m.multiJobs[i].message = "Generating key..."
+		cmds = append(cmds, multiKeyGenerateCmd(i, m.multiJobs[i].combo))
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// multiKeyFinished reports whether every job has completed or failed.
+func (m model) multiKeyFinished() bool {
+	for _, job := range m.multiJobs {
+		if !job.done {
+			return false
+		}
+	}
+	return true
+}
+
+// Handle key presses in the multi-key states
+func (m model) updateMultiKeyInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch m.state {
+	case "multi_selection":
+		combos := multiKeyCombos()
+		switch msg.String() {
+		case "up", "k":
+			if m.multiIdx > 0 {
+				m.multiIdx--
+			}
+		case "down", "j":
+			if m.multiIdx < len(combos)-1 {
+				m.multiIdx++
+			}
+		case " ", "x":
+			if m.multiSelected == nil {
+				m.multiSelected = make([]bool, len(combos))
+			}
+			m.multiSelected[m.multiIdx] = !m.multiSelected[m.multiIdx]
+		case "enter":
+			var selected []keyCombo
+			for i, c := range combos {
+				if i < len(m.multiSelected) && m.multiSelected[i] {
+					selected = append(selected, c)
+				}
+			}
+			if len(selected) == 0 {
+				return m, nil
+			}
+			m.multiJobs = buildMultiKeyJobs(selected, m.progress.Width)
+			for _, job := range m.multiJobs {
+				if exists, _ := checkExistingFiles(job.privatePath, job.publicPath); exists {
+					m.state = "multi_confirm"
+					return m, nil
+				}
+			}
+			return m.startMultiKeyGeneration()
+		case "esc", "b":
+			m.state = "algorithm_selection"
+		case "q", "Q", "ctrl+c":
+			return m, tea.Quit
+		}
+		return m, nil
+
+	case "multi_confirm":
+		switch msg.String() {
+		case "y", "Y":
+			return m.startMultiKeyGeneration()
+		case "n", "N", "esc":
+			m.state = "multi_selection"
+		case "q", "Q", "ctrl+c":
+			return m, tea.Quit
+		}
+		return m, nil
+
+	case "multi_complete":
+		if m.multiReload {
+			// Let the reload finish before exiting
+			return m, nil
+		}
+		// Wait for any key to exit
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// Handle progress messages from the concurrent jobs
+func (m model) updateMultiKeyProgress(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch msg := msg.(type) {
+	case multiKeyGeneratedMsg:
+		job := &m.multiJobs[msg.index]
+		job.percent = 0.6
+		job.message = "Key generated, writing files..."
+		cmd = multiKeyWriteCmd(msg.index, *job, msg.priv, m.comment, m.config)
+
+	case multiKeyWrittenMsg:
+		job := &m.multiJobs[msg.index]
+		job.percent = 1.0
+		job.message = "Done"
+		job.fingerprint = msg.fingerprint
+		job.warning = msg.warning
+		job.done = true
+
+	case multiKeyErrorMsg:
+		job := &m.multiJobs[msg.index]
+		job.message = "Failed"
+		job.err = msg.err
+		job.done = true
+
+	case multiKeyReloadedMsg:
+		m.multiReload = false
+		m.reload, m.reloadErr = msg.reload, msg.reloadErr
+		return m, nil
+	}
+
+	if m.multiKeyFinished() {
+		m.state = "multi_complete"
+		// The server is reloaded once, and only when every key was written
+		if m.config != nil && m.config.Reload.enabled() && m.multiKeyFailures() == 0 {
+			m.multiReload = true
+			cmd = tea.Batch(cmd, multiKeyReloadCmd(m.config.Reload))
+		}
+	}
+	return m, cmd
+}
+
+// multiKeyReloadCmd runs the reload hook after all keys have been written.
+func multiKeyReloadCmd(c reloadConfig) tea.Cmd {
+	return func() tea.Msg {
+		result, err := runReloadHook(c)
+		if err != nil {
+			return multiKeyReloadedMsg{reloadErr: err.Error()}
+		}
+		return multiKeyReloadedMsg{reload: result}
+	}
+}
+
+// multiKeyFailures counts the jobs that failed.
+func (m model) multiKeyFailures() int {
+	failed := 0
+	for _, job := range m.multiJobs {
+		if job.err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Render the multi-key states
+func (m model) viewMultiKey() string {
+	pad := strings.Repeat(" ", padding)
+
+	switch m.state {
+	case "multi_selection":
+		view := "\n" +
+			pad + titleStyle.Render(AppTitle) + "\n" +
+			pad + fmt.Sprintf("Version %s", AppVersion) + "\n\n" +
+			pad + "Select the keys to generate:\n\n"
+
+		for i, c := range multiKeyCombos() {
+			prefix := "  "
+			if i == m.multiIdx {
+				prefix = "▶ "
+			}
+			box := "[ ]"
+			if i < len(m.multiSelected) && m.multiSelected[i] {
+				box = "[x]"
+			}
+			view += pad + prefix + fmt.Sprintf(" %s %s", box, c.label()) + "\n"
+		}
+
+		view += "\n" + pad + helpStyle("Use ↑/↓ or j/k to navigate, Space to toggle, Enter to generate, b to go back, q to quit")
+		return view
+
+	case "multi_confirm":
+		view := "\n" +
+			pad + titleStyle.Render(AppTitle) + "\n\n" +
+			pad + warningStyle.Render("⚠️  Some files already exist:") + "\n"
+		for _, job := range m.multiJobs {
+			if exists, _ := checkExistingFiles(job.privatePath, job.publicPath); exists {
+				view += pad + fmt.Sprintf("   %s / %s", job.privatePath, job.publicPath) + "\n"
+			}
+		}
+		return view + "\n" +
+			pad + "Do you want to overwrite these files? (y/N): " + "\n\n" +
+			pad + helpStyle("Press 'y' to overwrite, 'n' to go back, 'q' to quit")
+
+	case "multi_generating":
+		view := "\n" +
+			pad + titleStyle.Render(AppTitle) + "\n\n"
+		for _, job := range m.multiJobs {
+			view += pad + fmt.Sprintf("%-12s %s", job.combo.label(), job.message) + "\n" +
+				pad + job.progress.ViewAs(job.percent) + "\n\n"
+		}
+		return view + pad + helpStyle("Please wait...")
+
+	case "multi_complete":
+		view := "\n" +
+			pad + titleStyle.Render(AppTitle) + "\n" +
+			pad + fmt.Sprintf("Version %s", AppVersion) + "\n\n"
+
+		failed := m.multiKeyFailures()
+		if failed == 0 {
+			view += pad + successStyle.Render(fmt.Sprintf("✅ %d keys generated successfully!", len(m.multiJobs))) + "\n\n"
+		} else {
+			view += pad + errorStyle.Render(fmt.Sprintf("❌ %d of %d keys failed", failed, len(m.multiJobs))) + "\n\n"
+		}
+
+		view += pad + fmt.Sprintf("%-12s %-18s %-22s %s", "Key", "Private key", "Public key", "Fingerprint") + "\n"
+		for _, job := range m.multiJobs {
+			result := job.fingerprint
+			if job.err != nil {
+				result = errorStyle.Render(job.err.Error())
+			}
+			view += pad + fmt.Sprintf("%-12s %-18s %-22s %s", job.combo.label(), job.privatePath, job.publicPath, result) + "\n"
+			if job.warning != "" {
+				view += pad + warningStyle.Render("Hook failed: ") + job.warning + "\n"
+			}
+		}
+		switch {
+		case m.multiReload:
+			return view + "\n" + pad + "Reloading server..." + "\n\n" +
+				pad + helpStyle("Please wait...")
+		case m.reload != "":
+			view += "\n" + pad + successStyle.Render("Server reloaded: ") + m.reload + "\n"
+		case m.reloadErr != "":
+			view += "\n" + pad + errorStyle.Render("Server reload failed: ") + m.reloadErr + "\n"
+		}
+		return view + "\n" +
+			pad + helpStyle("Press any key to exit")
+	}
+	return ""
+}
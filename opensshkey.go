@@ -0,0 +1,353 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : opensshkey.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 18:20:41
+ * Description  : openssh-key-v1 private keys with a chosen cipher and KDF rounds
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/blowfish"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// openssh-key-v1 constants
+const (
+	openSSHMagic         = "openssh-key-v1\x00"
+	openSSHDefaultCipher = "aes256-ctr"
+	openSSHDefaultRounds = 16
+)
+
+// Ciphers this tool writes; both are also read by golang.org/x/crypto/ssh
+var openSSHCiphers = []string{"aes256-ctr", "aes256-cbc"}
+
+// Container of an openssh-key-v1 file
+type openSSHContainer struct {
+	CipherName   string
+	KdfName      string
+	KdfOpts      string
+	NumKeys      uint32
+	PubKey       []byte
+	PrivKeyBlock []byte
+}
+
+// bcrypt KDF options
+type openSSHKdfOpts struct {
+	Salt   string
+	Rounds uint32
+}
+
+// Settings read from an existing openssh-key-v1 file
+type openSSHKeyInfo struct {
+	cipherName string
+	rounds     int
+	comment    string
+}
+
+// bcryptPBKDF implements the bcrypt_pbkdf key derivation used by OpenSSH.
+func bcryptPBKDF(password, salt []byte, rounds, keyLen int) ([]byte, error) {
+	const blockSize = 32
+	if rounds < 1 {
+		return nil, errors.New("bcrypt_pbkdf: rounds must be at least 1")
+	}
+	if len(password) == 0 || len(salt) == 0 || keyLen <= 0 || keyLen > 1024 {
+		return nil, errors.New("bcrypt_pbkdf: invalid parameters")
+	}
+	numBlocks := (keyLen + blockSize - 1) / blockSize
+	key := make([]byte, numBlocks*blockSize)
+
+	shapass := sha512.Sum512(password)
+	tmp := make([]byte, blockSize)
+	for block := 1; block <= numBlocks; block++ {
+		h := sha512.New()
+		h.Write(salt)
+		h.Write(binary.BigEndian.AppendUint32(nil, uint32(block)))
+		if err := bcryptHash(tmp, shapass[:], h.Sum(nil)); err != nil {
+			return nil, err
+		}
+		out := make([]byte, blockSize)
+		copy(out, tmp)
+		for i := 2; i <= rounds; i++ {
+			shasalt := sha512.Sum512(tmp)
+			if err := bcryptHash(tmp, shapass[:], shasalt[:]); err != nil {
+				return nil, err
+			}
+			for j := range out {
+				out[j] ^= tmp[j]
+			}
+		}
+		// Output bytes are interleaved across blocks
+		for i, v := range out {
+			key[i*numBlocks+(block-1)] = v
+		}
+	}
+	return key[:keyLen], nil
+}
+
+// bcryptHash is the modified bcrypt core of bcrypt_pbkdf.
+func bcryptHash(out, shapass, shasalt []byte) error {
+	c, err := blowfish.NewSaltedCipher(shapass, shasalt)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 64; i++ {
+		blowfish.ExpandKey(shasalt, c)
+		blowfish.ExpandKey(shapass, c)
+	}
+	copy(out, "OxychromaticBlowfishSwatDynamite")
+	for i := 0; i < 32; i += 8 {
+		for j := 0; j < 64; j++ {
+			c.Encrypt(out[i:i+8], out[i:i+8])
+		}
+	}
+	// Blowfish words are big endian; bcrypt_pbkdf stores them little endian
+	for i := 0; i < 32; i += 4 {
+		out[i], out[i+1], out[i+2], out[i+3] = out[i+3], out[i+2], out[i+1], out[i]
+	}
+	return nil
+}
+
+// openSSHStream returns the cipher for the private key block.
+func openSSHStream(cipherName string, key, iv []byte, encrypt bool) (func(dst, src []byte), error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	switch cipherName {
+	case "aes256-ctr":
+		return cipher.NewCTR(block, iv).XORKeyStream, nil
+	case "aes256-cbc":
+		if encrypt {
+			return cipher.NewCBCEncrypter(block, iv).CryptBlocks, nil
+		}
+		return cipher.NewCBCDecrypter(block, iv).CryptBlocks, nil
+	default:
+		return nil, fmt.Errorf("unsupported cipher %q (supported: aes256-ctr, aes256-cbc)", cipherName)
+	}
+}
+
+// openSSHKeyFields returns the key specific fields of the private section.
+func openSSHKeyFields(priv interface{}) ([]byte, error) {
+	switch k := priv.(type) {
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return nil, errors.New("only two-prime RSA keys are supported")
+		}
+		iqmp := new(big.Int).ModInverse(k.Primes[1], k.Primes[0])
+		return ssh.Marshal(struct {
+			N, E, D, Iqmp, P, Q *big.Int
+		}{k.N, big.NewInt(int64(k.E)), k.D, iqmp, k.Primes[0], k.Primes[1]}), nil
+	case ed25519.PrivateKey:
+		return ssh.Marshal(struct {
+			Pub  []byte
+			Priv []byte
+		}{k.Public().(ed25519.PublicKey), k}), nil
+	case *ecdsa.PrivateKey:
+		var curve string
+		switch k.Curve.Params().BitSize {
+		case 256:
+			curve = "nistp256"
+		case 384:
+			curve = "nistp384"
+		case 521:
+			curve = "nistp521"
+		default:
+			return nil, errors.New("unsupported ECDSA curve")
+		}
+		pub, err := k.PublicKey.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		return ssh.Marshal(struct {
+			Curve string
+			Pub   []byte
+			D     *big.Int
+		}{curve, pub.Bytes(), k.D}), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", priv)
+	}
+}
+
+// marshalOpenSSHPrivateKey encodes priv as a PEM openssh-key-v1 file. When
+// passphrase is empty the key is written unencrypted.
+func marshalOpenSSHPrivateKey(priv interface{}, comment string, passphrase []byte, cipherName string, rounds int) ([]byte, error) {
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := openSSHKeyFields(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	w := openSSHContainer{CipherName: "none", KdfName: "none", NumKeys: 1, PubKey: signer.PublicKey().Marshal()}
+	blockSize := 8
+	var key, iv []byte
+	if len(passphrase) > 0 {
+		if !containsString(openSSHCiphers, cipherName) {
+			return nil, fmt.Errorf("unsupported cipher %q (supported: aes256-ctr, aes256-cbc)", cipherName)
+		}
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		k, err := bcryptPBKDF(passphrase, salt, rounds, 32+aes.BlockSize)
+		if err != nil {
+			return nil, err
+		}
+		key, iv = k[:32], k[32:]
+		w.CipherName, w.KdfName = cipherName, "bcrypt"
+		w.KdfOpts = string(ssh.Marshal(openSSHKdfOpts{Salt: string(salt), Rounds: uint32(rounds)}))
+		blockSize = aes.BlockSize
+	}
+
+	// check ints, key type, key fields, comment and 1, 2, 3... padding
+	check := make([]byte, 4)
+	if _, err := rand.Read(check); err != nil {
+		return nil, err
+	}
+	var section bytes.Buffer
+	section.Write(check)
+	section.Write(check)
+	section.Write(ssh.Marshal(struct{ KeyType string }{signer.PublicKey().Type()}))
+	section.Write(fields)
+	section.Write(ssh.Marshal(struct{ Comment string }{comment}))
+	for i := 1; section.Len()%blockSize != 0; i++ {
+		section.WriteByte(byte(i))
+	}
+
+	w.PrivKeyBlock = section.Bytes()
+	if len(passphrase) > 0 {
+		crypt, err := openSSHStream(cipherName, key, iv, true)
+		if err != nil {
+			return nil, err
+		}
+		crypt(w.PrivKeyBlock, w.PrivKeyBlock)
+	}
+
+	data := append([]byte(openSSHMagic), ssh.Marshal(w)...)
+	return pem.EncodeToMemory(&pem.Block{Type: "OPENSSH PRIVATE KEY", Bytes: data}), nil
+}
+
+// parseOpenSSHContainer decodes the unencrypted header of an openssh-key-v1
+// file, which includes the public key.
+func parseOpenSSHContainer(data []byte) (*openSSHContainer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "OPENSSH PRIVATE KEY" {
+		return nil, errors.New("not an OpenSSH private key")
+	}
+	if !bytes.HasPrefix(block.Bytes, []byte(openSSHMagic)) {
+		return nil, errors.New("invalid openssh-key-v1 header")
+	}
+	var w openSSHContainer
+	if err := ssh.Unmarshal(block.Bytes[len(openSSHMagic):], &w); err != nil {
+		return nil, err
+	}
+	if w.NumKeys != 1 {
+		return nil, fmt.Errorf("unsupported number of keys %d", w.NumKeys)
+	}
+	return &w, nil
+}
+
+// readOpenSSHKeyInfo returns the cipher, KDF rounds and embedded comment of
+// an openssh-key-v1 file. passphrase is only used for encrypted keys.
+func readOpenSSHKeyInfo(data, passphrase []byte) (*openSSHKeyInfo, error) {
+	w, err := parseOpenSSHContainer(data)
+	if err != nil {
+		return nil, err
+	}
+	info := &openSSHKeyInfo{cipherName: w.CipherName}
+
+	section := w.PrivKeyBlock
+	if w.CipherName != "none" {
+		if w.KdfName != "bcrypt" {
+			return nil, fmt.Errorf("unsupported KDF %q", w.KdfName)
+		}
+		var opts openSSHKdfOpts
+		if err := ssh.Unmarshal([]byte(w.KdfOpts), &opts); err != nil {
+			return nil, err
+		}
+		info.rounds = int(opts.Rounds)
+		k, err := bcryptPBKDF(passphrase, []byte(opts.Salt), info.rounds, 32+aes.BlockSize)
+		if err != nil {
+			return nil, err
+		}
+		crypt, err := openSSHStream(w.CipherName, k[:32], k[32:], false)
+		if err != nil {
+			return nil, err
+		}
+		if len(section)%aes.BlockSize != 0 {
+			return nil, errors.New("invalid private key block length")
+		}
+		section = make([]byte, len(w.PrivKeyBlock))
+		crypt(section, w.PrivKeyBlock)
+	}
+
+	if len(section) < 8 || !bytes.Equal(section[:4], section[4:8]) {
+		return nil, errors.New("decryption failed: wrong passphrase")
+	}
+	rest := section[8:]
+	readString := func() ([]byte, error) {
+		if len(rest) < 4 {
+			return nil, errors.New("truncated private key block")
+		}
+		n := binary.BigEndian.Uint32(rest)
+		if uint64(n) > uint64(len(rest)-4) {
+			return nil, errors.New("truncated private key block")
+		}
+		s := rest[4 : 4+n]
+		rest = rest[4+n:]
+		return s, nil
+	}
+	keyType, err := readString()
+	if err != nil {
+		return nil, err
+	}
+	var fields int
+	switch kt := string(keyType); {
+	case kt == ssh.KeyAlgoRSA:
+		fields = 6
+	case kt == ssh.KeyAlgoED25519:
+		fields = 2
+	case kt == ssh.KeyAlgoECDSA256, kt == ssh.KeyAlgoECDSA384, kt == ssh.KeyAlgoECDSA521:
+		fields = 3
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", kt)
+	}
+	for i := 0; i < fields; i++ {
+		if _, err := readString(); err != nil {
+			return nil, err
+		}
+	}
+	comment, err := readString()
+	if err != nil {
+		return nil, err
+	}
+	info.comment = string(comment)
+	return info, nil
+}
@@ -0,0 +1,84 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : passphrase.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 09:12:40
+ * Description  : Passphrase source resolution (env:, file:, pass:) and terminal prompts
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// resolvePassphrase reads a passphrase from a source specification:
+//
+//	env:NAME   value of the environment variable NAME
+//	file:PATH  first line of the file at PATH
+//	pass:TEXT  the literal TEXT
+//
+// An empty source means no passphrase.
+func resolvePassphrase(source string) ([]byte, error) {
+	if source == "" {
+		return nil, nil
+	}
+	kind, value, ok := strings.Cut(source, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid passphrase source %q (expected env:, file: or pass:)", source)
+	}
+
+	switch kind {
+	case "env":
+		pass, ok := os.LookupEnv(value)
+		if !ok {
+			return nil, fmt.Errorf("passphrase environment variable %s is not set", value)
+		}
+		return []byte(pass), nil
+	case "file":
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return nil, fmt.Errorf("reading passphrase file: %w", err)
+		}
+		line, _, _ := strings.Cut(string(data), "\n")
+		return []byte(strings.TrimSuffix(line, "\r")), nil
+	case "pass":
+		return []byte(value), nil
+	default:
+		return nil, fmt.Errorf("invalid passphrase source %q (expected env:, file: or pass:)", source)
+	}
+}
+
+// promptPassphrase reads a passphrase from the terminal without echo.
+func promptPassphrase(prompt string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("a passphrase is required but standard input is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	pass, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return pass, err
+}
+
+// passphraseOrPrompt resolves source, falling back to a terminal prompt when it is empty.
+func passphraseOrPrompt(source, prompt string) ([]byte, error) {
+	if source != "" {
+		return resolvePassphrase(source)
+	}
+	return promptPassphrase(prompt)
+}
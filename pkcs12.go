@@ -0,0 +1,232 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : pkcs12.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 17:55:03
+ * Description  : PKCS#12 (.p12) bundle export
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	pkcs12 "software.sslmate.com/src/go-pkcs12"
+)
+
+// readCertificates reads every CERTIFICATE block of a PEM file.
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s: no certificates found", path)
+	}
+	return certs, nil
+}
+
+// certificateMatchesKey reports whether cert holds the public key of signer.
+func certificateMatchesKey(cert *x509.Certificate, signer crypto.Signer) bool {
+	a, err := ssh.NewPublicKey(cert.PublicKey)
+	if err != nil {
+		return false
+	}
+	b, err := ssh.NewPublicKey(signer.Public())
+	if err != nil {
+		return false
+	}
+	return keysEqual(a, b)
+}
+
+// placeholderCertificate creates the self-signed certificate PKCS#12
+// consumers such as Java keystores need next to a private key.
+func placeholderCertificate(signer crypto.Signer, commonName string, days int) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Truncate(time.Second)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now,
+		NotAfter:              now.AddDate(0, 0, days),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// Run the pkcs12 subcommand
+func runPKCS12(args []string) error {
+	fs := flag.NewFlagSet("pkcs12", flag.ExitOnError)
+	keyFile := fs.String("f", "id_rsa", "RSA or ECDSA private key to export")
+	passphrase := fs.String("passphrase", "", "passphrase source for an encrypted key (default: prompt)")
+	certFile := fs.String("cert", "", "PEM certificate for the key, optionally followed by its CA chain")
+	out := fs.String("o", "", "output file (default <key>.p12)")
+	password := fs.String("password", "", "bundle password source (env:, file:, pass:; default: prompt)")
+	legacy := fs.Bool("legacy", false, "use legacy 3DES/SHA-1 encryption for older consumers")
+	iterations := fs.Int("iterations", defaultPBKDF2Iterations, "PBKDF2 and MAC iterations (modern mode)")
+	name := fs.String("name", "", "common name of the generated certificate when -cert is not given (default: key comment or file name)")
+	days := fs.Int("days", 3650, "validity in days of the generated certificate when -cert is not given")
+	force := fs.Bool("force", false, "overwrite the output file")
+	configPath := fs.String("config", "", "configuration file with the audit log (default: $"+configEnv+" or the user config directory)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s pkcs12 -f <key> [-cert cert.pem] [-o bundle.p12] [flags]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		*out = *keyFile + ".p12"
+	}
+	if !*force && fileExists(*out) {
+		return fmt.Errorf("%s already exists (use -force to overwrite)", *out)
+	}
+	if *iterations < 1 || *days < 1 {
+		return errors.New("-iterations and -days must be positive")
+	}
+
+	priv, err := loadPrivateKey(*keyFile, *passphrase)
+	if err != nil {
+		return err
+	}
+	var signer crypto.Signer
+	switch k := priv.(type) {
+	case *rsa.PrivateKey:
+		signer = k
+	case *ecdsa.PrivateKey:
+		signer = k
+	default:
+		return fmt.Errorf("PKCS#12 export supports RSA and ECDSA keys, not %T", priv)
+	}
+
+	var cert *x509.Certificate
+	var chain []*x509.Certificate
+	if *certFile != "" {
+		certs, err := readCertificates(*certFile)
+		if err != nil {
+			return err
+		}
+		for _, c := range certs {
+			if cert == nil && certificateMatchesKey(c, signer) {
+				cert = c
+			} else {
+				chain = append(chain, c)
+			}
+		}
+		if cert == nil {
+			return fmt.Errorf("%s: no certificate matches the key %s", *certFile, *keyFile)
+		}
+	} else {
+		if *name == "" {
+			if _, comment, err := loadPublicKey(*keyFile + ".pub"); err == nil && comment != "" {
+				*name = comment
+			} else {
+				*name = strings.TrimSuffix(filepath.Base(*keyFile), filepath.Ext(*keyFile))
+			}
+		}
+		if cert, err = placeholderCertificate(signer, *name, *days); err != nil {
+			return err
+		}
+	}
+
+	pass, err := passphraseOrPrompt(*password, fmt.Sprintf("Enter password for %s: ", *out))
+	if err != nil {
+		return err
+	}
+	if len(pass) == 0 {
+		return errors.New("the bundle password must not be empty")
+	}
+
+	encoder := pkcs12.Modern.WithIterations(*iterations)
+	mode := "AES-256-CBC/PBKDF2-SHA256"
+	if *legacy {
+		encoder = pkcs12.LegacyDES
+		mode = "legacy 3DES/SHA-1"
+	}
+	data, err := encoder.Encode(priv, cert, chain, string(pass))
+	if err != nil {
+		return err
+	}
+
+	// Read the bundle back before writing it
+	decoded, _, _, err := pkcs12.DecodeChain(data, string(pass))
+	if err != nil {
+		return fmt.Errorf("verifying bundle: %w", err)
+	}
+	pub, err := ssh.NewPublicKey(signer.Public())
+	if err != nil {
+		return err
+	}
+	if err := verifyKeyPair(normalizePrivateKey(decoded), pub); err != nil {
+		return fmt.Errorf("verifying bundle: %w", err)
+	}
+
+	if err := writeFileAtomic(*out, data, 0o600); err != nil {
+		return err
+	}
+	if err := logConversion(config, *keyFile, signer, *out); err != nil {
+		return err
+	}
+	fmt.Printf("PKCS#12 bundle (%s) saved to %s (permissions 0600)\n", mode, *out)
+	fmt.Printf("Certificate: %s", cert.Subject)
+	if *certFile == "" {
+		fmt.Print(" (self-signed, generated)")
+	}
+	fmt.Println()
+	if len(chain) > 0 {
+		fmt.Printf("CA certificates: %d\n", len(chain))
+	}
+	return nil
+}
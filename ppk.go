@@ -0,0 +1,655 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : ppk.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 15:12:44
+ * Description  : PuTTY PPK version 2 and 3 export and import
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// PPK constants
+const (
+	ppkEncryptionNone = "none"
+	ppkEncryptionAES  = "aes256-cbc"
+	ppkLineLength     = 64
+	ppkV2MACKeyPrefix = "putty-private-key-file-mac-key"
+)
+
+// Argon2 parameters written to version 3 files
+type ppkArgon2Params struct {
+	flavour     string // Argon2id, Argon2i
+	memory      uint32 // KiB
+	passes      uint32
+	parallelism uint8
+	salt        []byte
+}
+
+// Default Argon2 parameters, matching what PuTTYgen typically chooses
+var defaultPPKArgon2 = ppkArgon2Params{
+	flavour:     "Argon2id",
+	memory:      8192,
+	passes:      13,
+	parallelism: 1,
+}
+
+// Decoded contents of a PPK file
+type ppkFile struct {
+	version    int
+	algorithm  string
+	encryption string
+	comment    string
+	public     []byte
+	private    []byte // encrypted when encryption is not none
+	argon2     ppkArgon2Params
+	mac        []byte
+}
+
+// Private key blobs as stored in PPK files
+type ppkRSAPrivate struct {
+	D    *big.Int
+	P    *big.Int
+	Q    *big.Int
+	Iqmp *big.Int
+	Rest []byte `ssh:"rest"`
+}
+type ppkEd25519Private struct {
+	Seed []byte
+	Rest []byte `ssh:"rest"`
+}
+type ppkECDSAPrivate struct {
+	D    *big.Int
+	Rest []byte `ssh:"rest"`
+}
+
+// Data covered by the PPK MAC
+type ppkMACData struct {
+	Algorithm  string
+	Encryption string
+	Comment    string
+	Public     []byte
+	Private    []byte
+}
+
+// ppkPrivateBlob encodes the private half of a key in PuTTY's layout.
+func ppkPrivateBlob(priv interface{}) ([]byte, error) {
+	switch k := priv.(type) {
+	case *rsa.PrivateKey:
+		if len(k.Primes) != 2 {
+			return nil, errors.New("PPK supports only two-prime RSA keys")
+		}
+		p, q := k.Primes[0], k.Primes[1]
+		iqmp := new(big.Int).ModInverse(q, p)
+		return ssh.Marshal(ppkRSAPrivate{D: k.D, P: p, Q: q, Iqmp: iqmp}), nil
+	case ed25519.PrivateKey:
+		return ssh.Marshal(ppkEd25519Private{Seed: k.Seed()}), nil
+	case *ecdsa.PrivateKey:
+		return ssh.Marshal(ppkECDSAPrivate{D: k.D}), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", priv)
+	}
+}
+
+// ppkKeys derives the cipher key, IV and MAC key for a PPK file.
+func ppkKeys(version int, encrypted bool, passphrase []byte, params ppkArgon2Params) (key, iv, macKey []byte, err error) {
+	if version == 2 {
+		if encrypted {
+			h0 := sha1.Sum(append([]byte{0, 0, 0, 0}, passphrase...))
+			h1 := sha1.Sum(append([]byte{0, 0, 0, 1}, passphrase...))
+			key = append(h0[:], h1[:]...)[:32]
+			iv = make([]byte, aes.BlockSize)
+		} else {
+			passphrase = nil
+		}
+		mk := sha1.Sum(append([]byte(ppkV2MACKeyPrefix), passphrase...))
+		return key, iv, mk[:], nil
+	}
+
+	if !encrypted {
+		return nil, nil, nil, nil
+	}
+	var out []byte
+	switch params.flavour {
+	case "Argon2id":
+		out = argon2.IDKey(passphrase, params.salt, params.passes, params.memory, params.parallelism, 80)
+	case "Argon2i":
+		out = argon2.Key(passphrase, params.salt, params.passes, params.memory, params.parallelism, 80)
+	default:
+		return nil, nil, nil, fmt.Errorf("unsupported key derivation %q", params.flavour)
+	}
+	return out[:32], out[32:48], out[48:], nil
+}
+
+// ppkMAC computes the Private-MAC value.
+func ppkMAC(version int, macKey []byte, f *ppkFile, private []byte) []byte {
+	var h func() hash.Hash = sha256.New
+	if version == 2 {
+		h = sha1.New
+	}
+	mac := hmac.New(h, macKey)
+	mac.Write(ssh.Marshal(ppkMACData{
+		Algorithm:  f.algorithm,
+		Encryption: f.encryption,
+		Comment:    f.comment,
+		Public:     f.public,
+		Private:    private,
+	}))
+	return mac.Sum(nil)
+}
+
+// marshalPPK encodes a private key as a PPK file of the given version. The
+// key is encrypted with AES-256-CBC when passphrase is non-empty.
+func marshalPPK(priv interface{}, comment string, version int, passphrase []byte, params ppkArgon2Params) ([]byte, error) {
+	if version != 2 && version != 3 {
+		return nil, fmt.Errorf("unsupported PPK version %d (supported: 2, 3)", version)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	private, err := ppkPrivateBlob(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &ppkFile{
+		version:    version,
+		algorithm:  signer.PublicKey().Type(),
+		encryption: ppkEncryptionNone,
+		comment:    comment,
+		public:     signer.PublicKey().Marshal(),
+	}
+	encrypted := len(passphrase) > 0
+	if encrypted {
+		f.encryption = ppkEncryptionAES
+		// Pad to the cipher block size
+		if rem := len(private) % aes.BlockSize; rem != 0 {
+			pad := make([]byte, aes.BlockSize-rem)
+			if version == 2 {
+				sum := sha1.Sum(private)
+				copy(pad, sum[:])
+			} else if _, err := rand.Read(pad); err != nil {
+				return nil, err
+			}
+			private = append(private, pad...)
+		}
+		if version == 3 {
+			f.argon2 = params
+			f.argon2.salt = make([]byte, 16)
+			if _, err := rand.Read(f.argon2.salt); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	key, iv, macKey, err := ppkKeys(version, encrypted, passphrase, f.argon2)
+	if err != nil {
+		return nil, err
+	}
+	f.mac = ppkMAC(version, macKey, f, private)
+
+	f.private = private
+	if encrypted {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		f.private = make([]byte, len(private))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(f.private, private)
+	}
+	return f.encode(), nil
+}
+
+// encode renders the file in PuTTY's text layout.
+func (f *ppkFile) encode() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "PuTTY-User-Key-File-%d: %s\n", f.version, f.algorithm)
+	fmt.Fprintf(&b, "Encryption: %s\n", f.encryption)
+	fmt.Fprintf(&b, "Comment: %s\n", f.comment)
+	writePPKLines(&b, "Public-Lines", f.public)
+	if f.version == 3 && f.encryption != ppkEncryptionNone {
+		fmt.Fprintf(&b, "Key-Derivation: %s\n", f.argon2.flavour)
+		fmt.Fprintf(&b, "Argon2-Memory: %d\n", f.argon2.memory)
+		fmt.Fprintf(&b, "Argon2-Passes: %d\n", f.argon2.passes)
+		fmt.Fprintf(&b, "Argon2-Parallelism: %d\n", f.argon2.parallelism)
+		fmt.Fprintf(&b, "Argon2-Salt: %s\n", hex.EncodeToString(f.argon2.salt))
+	}
+	writePPKLines(&b, "Private-Lines", f.private)
+	fmt.Fprintf(&b, "Private-MAC: %s\n", hex.EncodeToString(f.mac))
+	return b.Bytes()
+}
+
+// writePPKLines writes a "<name>: N" header followed by base64 lines.
+func writePPKLines(b *bytes.Buffer, name string, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	n := (len(encoded) + ppkLineLength - 1) / ppkLineLength
+	fmt.Fprintf(b, "%s: %d\n", name, n)
+	for len(encoded) > ppkLineLength {
+		b.WriteString(encoded[:ppkLineLength] + "\n")
+		encoded = encoded[ppkLineLength:]
+	}
+	b.WriteString(encoded + "\n")
+}
+
+// parsePPKFile reads the headers and blobs of a PPK file without decrypting it.
+func parsePPKFile(data []byte) (*ppkFile, error) {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	next := func() (string, string, error) {
+		if !sc.Scan() {
+			return "", "", errors.New("unexpected end of PPK file")
+		}
+		name, value, ok := strings.Cut(strings.TrimRight(sc.Text(), "\r"), ": ")
+		if !ok {
+			return "", "", fmt.Errorf("malformed PPK line %q", sc.Text())
+		}
+		return name, value, nil
+	}
+	readLines := func(count string) ([]byte, error) {
+		n, err := strconv.Atoi(count)
+		if err != nil || n < 0 || n > 1024 {
+			return nil, fmt.Errorf("invalid line count %q", count)
+		}
+		var encoded strings.Builder
+		for i := 0; i < n; i++ {
+			if !sc.Scan() {
+				return nil, errors.New("unexpected end of PPK file")
+			}
+			encoded.WriteString(strings.TrimSpace(sc.Text()))
+		}
+		return base64.StdEncoding.DecodeString(encoded.String())
+	}
+
+	f := &ppkFile{}
+	name, value, err := next()
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "PuTTY-User-Key-File-2":
+		f.version = 2
+	case "PuTTY-User-Key-File-3":
+		f.version = 3
+	default:
+		return nil, errors.New("not a PuTTY version 2 or 3 key file")
+	}
+	f.algorithm = value
+
+	for {
+		name, value, err := next()
+		if err != nil {
+			return nil, err
+		}
+		switch name {
+		case "Encryption":
+			f.encryption = value
+		case "Comment":
+			f.comment = value
+		case "Public-Lines":
+			if f.public, err = readLines(value); err != nil {
+				return nil, err
+			}
+		case "Key-Derivation":
+			f.argon2.flavour = value
+		case "Argon2-Memory", "Argon2-Passes", "Argon2-Parallelism":
+			n, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q", name, value)
+			}
+			switch name {
+			case "Argon2-Memory":
+				f.argon2.memory = uint32(n)
+			case "Argon2-Passes":
+				f.argon2.passes = uint32(n)
+			default:
+				if n == 0 || n > 255 {
+					return nil, fmt.Errorf("invalid %s %q", name, value)
+				}
+				f.argon2.parallelism = uint8(n)
+			}
+		case "Argon2-Salt":
+			if f.argon2.salt, err = hex.DecodeString(value); err != nil {
+				return nil, fmt.Errorf("invalid Argon2-Salt: %w", err)
+			}
+		case "Private-Lines":
+			if f.private, err = readLines(value); err != nil {
+				return nil, err
+			}
+		case "Private-MAC":
+			if f.mac, err = hex.DecodeString(value); err != nil {
+				return nil, fmt.Errorf("invalid Private-MAC: %w", err)
+			}
+			return f, nil
+		default:
+			return nil, fmt.Errorf("unexpected PPK header %q", name)
+		}
+	}
+}
+
+// parsePPK decodes a PPK file, verifying its MAC, and returns the private key
+// and comment. passphrase is only used for encrypted files.
+func parsePPK(data, passphrase []byte) (interface{}, string, error) {
+	f, err := parsePPKFile(data)
+	if err != nil {
+		return nil, "", err
+	}
+	encrypted := false
+	switch f.encryption {
+	case ppkEncryptionNone:
+	case ppkEncryptionAES:
+		encrypted = true
+		if len(f.private)%aes.BlockSize != 0 {
+			return nil, "", errors.New("encrypted private key is not a multiple of the block size")
+		}
+	default:
+		return nil, "", fmt.Errorf("unsupported PPK encryption %q", f.encryption)
+	}
+
+	key, iv, macKey, err := ppkKeys(f.version, encrypted, passphrase, f.argon2)
+	if err != nil {
+		return nil, "", err
+	}
+	private := f.private
+	if encrypted {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, "", err
+		}
+		private = make([]byte, len(f.private))
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(private, f.private)
+	}
+	if subtle.ConstantTimeCompare(ppkMAC(f.version, macKey, f, private), f.mac) != 1 {
+		if encrypted {
+			return nil, "", errors.New("MAC check failed: wrong passphrase or corrupted file")
+		}
+		return nil, "", errors.New("MAC check failed: corrupted file")
+	}
+
+	pub, err := ssh.ParsePublicKey(f.public)
+	if err != nil {
+		return nil, "", err
+	}
+	if pub.Type() != f.algorithm {
+		return nil, "", fmt.Errorf("public key type %s does not match header %s", pub.Type(), f.algorithm)
+	}
+	priv, err := ppkPrivateKey(pub, private)
+	if err != nil {
+		return nil, "", err
+	}
+	return priv, f.comment, nil
+}
+
+// ppkPrivateKey rebuilds a private key from the public key and PuTTY's private blob.
+func ppkPrivateKey(pub ssh.PublicKey, private []byte) (interface{}, error) {
+	cpk, ok := pub.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %s", pub.Type())
+	}
+
+	switch k := cpk.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		var blob ppkRSAPrivate
+		if err := ssh.Unmarshal(private, &blob); err != nil {
+			return nil, fmt.Errorf("invalid RSA private key: %w", err)
+		}
+		priv := &rsa.PrivateKey{
+			PublicKey: *k,
+			D:         blob.D,
+			Primes:    []*big.Int{blob.P, blob.Q},
+		}
+		if err := priv.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid RSA private key: %w", err)
+		}
+		priv.Precompute()
+		return priv, nil
+
+	case ed25519.PublicKey:
+		var blob ppkEd25519Private
+		if err := ssh.Unmarshal(private, &blob); err != nil {
+			return nil, fmt.Errorf("invalid Ed25519 private key: %w", err)
+		}
+		// PuTTY stores the seed as a minimal-length little-endian integer, so
+		// a seed ending in zero bytes is shorter; those bytes go back at the end.
+		if len(blob.Seed) > ed25519.SeedSize {
+			return nil, errors.New("invalid Ed25519 private key length")
+		}
+		seed := make([]byte, ed25519.SeedSize)
+		copy(seed, blob.Seed)
+		priv := ed25519.NewKeyFromSeed(seed)
+		if !bytes.Equal(priv.Public().(ed25519.PublicKey), k) {
+			return nil, errors.New("Ed25519 private key does not match public key")
+		}
+		return priv, nil
+
+	case *ecdsa.PublicKey:
+		var blob ppkECDSAPrivate
+		if err := ssh.Unmarshal(private, &blob); err != nil {
+			return nil, fmt.Errorf("invalid ECDSA private key: %w", err)
+		}
+		if blob.D.Sign() <= 0 || blob.D.Cmp(k.Curve.Params().N) >= 0 {
+			return nil, errors.New("ECDSA private scalar out of range")
+		}
+		x, y := k.Curve.ScalarBaseMult(blob.D.Bytes())
+		if x.Cmp(k.X) != 0 || y.Cmp(k.Y) != 0 {
+			return nil, errors.New("ECDSA private key does not match public key")
+		}
+		return &ecdsa.PrivateKey{PublicKey: *k, D: blob.D}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported PPK key type %s", pub.Type())
+	}
+}
+
+// Run the ppk subcommand
+func runPPK(args []string) error {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s ppk <export|import> [flags]\n", filepath.Base(os.Args[0]))
+	}
+	if len(args) == 0 {
+		usage()
+		return errors.New("missing ppk action")
+	}
+	switch args[0] {
+	case "export":
+		return runPPKExport(args[1:])
+	case "import":
+		return runPPKImport(args[1:])
+	default:
+		usage()
+		return fmt.Errorf("unknown ppk action %q", args[0])
+	}
+}
+
+// Run ppk export
+func runPPKExport(args []string) error {
+	fs := flag.NewFlagSet("ppk export", flag.ExitOnError)
+	keyFile := fs.String("f", "id_rsa", "private key to export")
+	out := fs.String("o", "", "output file (default <key>.ppk)")
+	version := fs.Int("version", 3, "PPK format version (3, or 2 for legacy clients)")
+	comment := fs.String("C", "", "key comment (default: comment of <key>.pub)")
+	passphrase := fs.String("passphrase", "", "passphrase source to unlock an encrypted input key")
+	ppkPassphrase := fs.String("ppk-passphrase", "", "passphrase source to encrypt the PPK file (env:, file:, pass:; empty = unencrypted)")
+	kdf := fs.String("kdf", defaultPPKArgon2.flavour, "version 3 key derivation (Argon2id or Argon2i)")
+	memory := fs.Uint("argon2-memory", uint(defaultPPKArgon2.memory), "version 3 Argon2 memory in KiB")
+	passes := fs.Uint("argon2-passes", uint(defaultPPKArgon2.passes), "version 3 Argon2 passes")
+	parallelism := fs.Uint("argon2-parallelism", uint(defaultPPKArgon2.parallelism), "version 3 Argon2 parallelism")
+	force := fs.Bool("force", false, "overwrite the output file")
+	configPath := fs.String("config", "", "configuration file with the audit log (default: $"+configEnv+" or the user config directory)")
+	fs.Parse(args)
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		*out = *keyFile + ".ppk"
+	}
+	if !*force && fileExists(*out) {
+		return fmt.Errorf("%s already exists (use -force to overwrite)", *out)
+	}
+	if *parallelism < 1 || *parallelism > 255 || *memory == 0 || *passes == 0 {
+		return errors.New("invalid Argon2 parameters")
+	}
+
+	priv, err := loadPrivateKey(*keyFile, *passphrase)
+	if err != nil {
+		return err
+	}
+	if *comment == "" {
+		if _, c, err := loadPublicKey(*keyFile + ".pub"); err == nil {
+			*comment = c
+		}
+	}
+	encPass, err := resolvePassphrase(*ppkPassphrase)
+	if err != nil {
+		return err
+	}
+
+	params := ppkArgon2Params{
+		flavour:     *kdf,
+		memory:      uint32(*memory),
+		passes:      uint32(*passes),
+		parallelism: uint8(*parallelism),
+	}
+	data, err := marshalPPK(priv, *comment, *version, encPass, params)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(*out, data, 0o600); err != nil {
+		return err
+	}
+	if err := logConversion(config, *keyFile, priv, *out); err != nil {
+		return err
+	}
+	fmt.Printf("PuTTY key (PPK version %d) saved to %s (permissions 0600)\n", *version, *out)
+	return nil
+}
+
+// Run ppk import
+func runPPKImport(args []string) error {
+	fs := flag.NewFlagSet("ppk import", flag.ExitOnError)
+	in := fs.String("i", "", "PPK file to import")
+	out := fs.String("f", "", "output private key file (public will be <f>.pub)")
+	passphrase := fs.String("passphrase", "", "passphrase source for an encrypted PPK file (default: prompt)")
+	newPassphrase := fs.String("new-passphrase", "", "passphrase source to encrypt the imported key (OpenSSH format)")
+	force := fs.Bool("force", false, "overwrite existing files")
+	configPath := fs.String("config", "", "configuration file with the audit log (default: $"+configEnv+" or the user config directory)")
+	fs.Parse(args)
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	if *in == "" || *out == "" {
+		fs.Usage()
+		return errors.New("-i and -f are required")
+	}
+	if !*force && (fileExists(*out) || fileExists(*out+".pub")) {
+		return fmt.Errorf("%s or %s.pub already exists (use -force to overwrite)", *out, *out)
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+	f, err := parsePPKFile(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *in, err)
+	}
+	var pass []byte
+	if f.encryption != ppkEncryptionNone {
+		if pass, err = passphraseOrPrompt(*passphrase, fmt.Sprintf("Enter passphrase for %s: ", *in)); err != nil {
+			return err
+		}
+	}
+	priv, comment, err := parsePPK(data, pass)
+	if err != nil {
+		return fmt.Errorf("%s: %w", *in, err)
+	}
+
+	newPass, err := resolvePassphrase(*newPassphrase)
+	if err != nil {
+		return err
+	}
+	if err := writeImportedKey(priv, comment, *out, newPass); err != nil {
+		return err
+	}
+	if err := logConversion(config, *in, priv, *out, *out+".pub"); err != nil {
+		return err
+	}
+	fmt.Printf("Private key saved to %s (permissions 0600)\n", *out)
+	fmt.Printf("Public key saved to %s.pub (permissions 0644)\n", *out)
+	return nil
+}
+
+// writeImportedKey writes an imported private key and its public half in the
+// formats this tool generates: PEM, or encrypted OpenSSH with a passphrase.
+func writeImportedKey(priv interface{}, comment, privatePath string, passphrase []byte) error {
+	algorithm, err := privateKeyAlgorithm(priv)
+	if err != nil {
+		return err
+	}
+	var privPEM []byte
+	if len(passphrase) > 0 {
+		privPEM, err = encodePrivateKeyWithPassphrase(priv, comment, passphrase)
+	} else {
+		privPEM, err = encodePrivateKeyToPEM(priv, algorithm)
+	}
+	if err != nil {
+		return err
+	}
+	pubKey, err := publicKeySSHPublicKey(priv, algorithm, comment)
+	if err != nil {
+		return err
+	}
+	privBackup, err := backupKeyFile(privatePath)
+	if err != nil {
+		return err
+	}
+	pubBackup, err := backupKeyFile(privatePath + ".pub")
+	if err != nil {
+		return err
+	}
+	return writeKeyPair(privatePath, privatePath+".pub", privPEM, pubKey, privBackup, pubBackup)
+}
@@ -0,0 +1,85 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : ppk_test.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 23:02:44
+ * Description  : Tests for PuTTY private key files
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+// seedEndingInZero returns an Ed25519 seed whose last byte is 0x00, the case
+// PuTTY writes as a shorter integer.
+func seedEndingInZero(t *testing.T) []byte {
+	t.Helper()
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		t.Fatal(err)
+	}
+	seed[0] |= 1
+	seed[ed25519.SeedSize-1] = 0
+	return seed
+}
+
+func TestPPKEd25519SeedEndingInZero(t *testing.T) {
+	seed := seedEndingInZero(t)
+	priv := ed25519.NewKeyFromSeed(seed)
+
+	// Our own export and import
+	data, err := marshalPPK(priv, "zero@host", 3, nil, defaultPPKArgon2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, comment, err := parsePPK(data, nil)
+	if err != nil {
+		t.Fatalf("parsePPK: %v", err)
+	}
+	if k, ok := got.(ed25519.PrivateKey); !ok || !bytes.Equal(k, priv) || comment != "zero@host" {
+		t.Fatalf("round trip returned %T %q", got, comment)
+	}
+
+	// The same key as PuTTY writes it, with the trailing zero byte dropped
+	f, err := parsePPKFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.private = ssh.Marshal(ppkEd25519Private{Seed: seed[:ed25519.SeedSize-1]})
+	f.mac = ppkMAC(3, nil, f, f.private)
+	got, _, err = parsePPK(f.encode(), nil)
+	if err != nil {
+		t.Fatalf("parsePPK of PuTTY layout: %v", err)
+	}
+	if k, ok := got.(ed25519.PrivateKey); !ok || !bytes.Equal(k, priv) {
+		t.Fatal("PuTTY layout imported a different key")
+	}
+}
+
+func TestPPKEd25519RejectsLongSeed(t *testing.T) {
+	priv := ed25519.NewKeyFromSeed(seedEndingInZero(t))
+	pub, err := ssh.NewPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := ssh.Marshal(ppkEd25519Private{Seed: append(priv.Seed(), 0)})
+	if _, err := ppkPrivateKey(pub, blob); err == nil {
+		t.Fatal("33-byte Ed25519 private key accepted")
+	}
+}
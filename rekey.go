@@ -0,0 +1,273 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : rekey.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 18:47:15
+ * Description  : Changing the passphrase, encryption and comment of an existing key
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// What to change in an existing key
+type rekeyOptions struct {
+	path             string
+	oldPassphrase    string // source, prompted when empty
+	changePassphrase bool
+	newPassphrase    string // source, prompted when empty
+	changeComment    bool
+	comment          string
+	cipherName       string // empty keeps the current cipher
+	rounds           int    // 0 keeps the current rounds
+	backup           bool
+	config           *appConfig // audit log settings; nil reads the default file
+}
+
+// promptNewPassphrase asks for a new passphrase twice. An empty passphrase
+// removes the encryption.
+func promptNewPassphrase() ([]byte, error) {
+	first, err := promptPassphrase("Enter new passphrase (empty for no passphrase): ")
+	if err != nil {
+		return nil, err
+	}
+	second, err := promptPassphrase("Enter same passphrase again: ")
+	if err != nil {
+		return nil, err
+	}
+	if string(first) != string(second) {
+		return nil, errors.New("passphrases do not match")
+	}
+	return first, nil
+}
+
+// rekeyPrivateKey changes the passphrase, encryption settings and/or comment
+// of a private key and its .pub file, replacing both atomically.
+func rekeyPrivateKey(opts rekeyOptions) error {
+	info, err := os.Stat(opts.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(opts.path)
+	if err != nil {
+		return err
+	}
+
+	// Open the key
+	var oldPass []byte
+	priv, err := ssh.ParseRawPrivateKey(data)
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if oldPass, err = passphraseOrPrompt(opts.oldPassphrase, fmt.Sprintf("Enter old passphrase for %s: ", opts.path)); err != nil {
+			return err
+		}
+		priv, err = ssh.ParseRawPrivateKeyWithPassphrase(data, oldPass)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", opts.path, err)
+	}
+	priv = normalizePrivateKey(priv)
+	algorithm, err := privateKeyAlgorithm(priv)
+	if err != nil {
+		return err
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return err
+	}
+
+	// Current settings: OpenSSH files carry their own comment and KDF settings
+	isOpenSSH := false
+	comment, cipherName, rounds := "", openSSHDefaultCipher, openSSHDefaultRounds
+	if block, _ := pem.Decode(data); block != nil && block.Type == "OPENSSH PRIVATE KEY" {
+		isOpenSSH = true
+		current, err := readOpenSSHKeyInfo(data, oldPass)
+		if err != nil {
+			return fmt.Errorf("%s: %w", opts.path, err)
+		}
+		comment = current.comment
+		if current.cipherName != "none" {
+			cipherName, rounds = current.cipherName, current.rounds
+		}
+	}
+
+	pubPath := opts.path + ".pub"
+	pubInfo, pubErr := os.Stat(pubPath)
+	var pubOptions []string
+	if pubErr == nil {
+		pubData, err := os.ReadFile(pubPath)
+		if err != nil {
+			return err
+		}
+		pub, pubComment, options, _, err := ssh.ParseAuthorizedKey(pubData)
+		if err != nil {
+			return fmt.Errorf("%s: %w", pubPath, err)
+		}
+		if !keysEqual(pub, signer.PublicKey()) {
+			return fmt.Errorf("%s does not match %s", pubPath, opts.path)
+		}
+		pubOptions = options
+		if comment == "" {
+			comment = pubComment
+		}
+	}
+
+	// Apply the requested changes
+	if opts.changeComment {
+		comment = opts.comment
+	}
+	newPass := oldPass
+	if opts.changePassphrase {
+		if opts.newPassphrase != "" {
+			newPass, err = resolvePassphrase(opts.newPassphrase)
+		} else {
+			newPass, err = promptNewPassphrase()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if opts.cipherName != "" {
+		cipherName = opts.cipherName
+	}
+	if opts.rounds != 0 {
+		rounds = opts.rounds
+	}
+	if !containsString(openSSHCiphers, cipherName) {
+		return fmt.Errorf("unsupported cipher %q (supported: %s)", cipherName, strings.Join(openSSHCiphers, ", "))
+	}
+	if rounds < 1 {
+		return errors.New("KDF rounds must be at least 1")
+	}
+	if (opts.cipherName != "" || opts.rounds != 0) && len(newPass) == 0 {
+		return errors.New("cipher and KDF rounds only apply to encrypted keys")
+	}
+
+	// Encrypted keys and OpenSSH files stay in the OpenSSH format, which also
+	// stores the comment; unencrypted PEM keys stay PEM.
+	var privData []byte
+	if len(newPass) > 0 || isOpenSSH {
+		privData, err = marshalOpenSSHPrivateKey(priv, comment, newPass, cipherName, rounds)
+	} else {
+		privData, err = encodePrivateKeyToPEM(priv, algorithm)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Check the new file opens with the new passphrase and holds the same key
+	var check interface{}
+	if len(newPass) > 0 {
+		check, err = ssh.ParseRawPrivateKeyWithPassphrase(privData, newPass)
+	} else {
+		check, err = ssh.ParseRawPrivateKey(privData)
+	}
+	if err != nil {
+		return fmt.Errorf("verifying new key file: %w", err)
+	}
+	if err := verifyKeyPair(normalizePrivateKey(check), signer.PublicKey()); err != nil {
+		return fmt.Errorf("verifying new key file: %w", err)
+	}
+
+	var pubData []byte
+	if pubErr == nil || opts.changeComment {
+		line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+		if len(pubOptions) > 0 {
+			line = strings.Join(pubOptions, ",") + " " + line
+		}
+		if comment != "" {
+			line += " " + comment
+		}
+		pubData = []byte(line + "\n")
+	}
+
+	if opts.backup {
+		if err := writeFileAtomic(opts.path+".bak", data, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("writing backup: %w", err)
+		}
+		if pubErr == nil {
+			old, err := os.ReadFile(pubPath)
+			if err != nil {
+				return err
+			}
+			if err := writeFileAtomic(pubPath+".bak", old, pubInfo.Mode().Perm()); err != nil {
+				return fmt.Errorf("writing backup: %w", err)
+			}
+		}
+	}
+
+	// Both files are restored if either write or the audit record fails
+	privBackup, err := backupKeyFile(opts.path)
+	if err != nil {
+		return err
+	}
+	backups := []keyFileBackup{privBackup}
+	if pubData != nil {
+		pubBackup, err := backupKeyFile(pubPath)
+		if err != nil {
+			return err
+		}
+		backups = append(backups, pubBackup)
+	}
+
+	if err := writeFileAtomic(opts.path, privData, info.Mode().Perm()); err != nil {
+		return rollbackKeyFiles(fmt.Errorf("writing private key: %w", err), backups...)
+	}
+	if pubData != nil {
+		perm := os.FileMode(0o644)
+		if pubErr == nil {
+			perm = pubInfo.Mode().Perm()
+		}
+		if err := writeFileAtomic(pubPath, pubData, perm); err != nil {
+			return rollbackKeyFiles(fmt.Errorf("writing public key: %w", err), backups...)
+		}
+	}
+
+	written := []string{opts.path}
+	if pubData != nil {
+		written = append(written, pubPath)
+	}
+	rec := newAuditRecord(auditConverted, signer.PublicKey(), written...)
+	rec.Source = rec.Paths[0]
+	switch {
+	case opts.changePassphrase && opts.changeComment:
+		rec.Command = "change passphrase and comment"
+	case opts.changePassphrase:
+		rec.Command = "change passphrase"
+	default:
+		rec.Command = "change comment"
+	}
+	if err := logKeyEvent(opts.config, rec); err != nil {
+		return rollbackKeyFiles(err, backups...)
+	}
+
+	if len(newPass) > 0 {
+		fmt.Printf("Private key %s saved encrypted (%s, %d KDF rounds)\n", opts.path, cipherName, rounds)
+	} else {
+		fmt.Printf("Private key %s saved without encryption\n", opts.path)
+	}
+	if opts.changeComment {
+		fmt.Printf("Comment set to %q\n", comment)
+	}
+	if opts.backup {
+		fmt.Printf("Previous files kept as %s.bak\n", opts.path)
+	}
+	return nil
+}
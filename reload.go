@@ -0,0 +1,164 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : reload.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 22:41:36
+ * Description  : Signalling or restarting the server after its keys were written
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signal sent when a process is configured without one
+const defaultReloadSignal = "HUP"
+
+// Post-write reload hook: a signal to a process found by pid file or name,
+// or a command to run
+type reloadConfig struct {
+	Signal  string        `yaml:"signal" json:"signal"`     // e.g. HUP, USR1, TERM
+	PidFile string        `yaml:"pid_file" json:"pid_file"` // file holding the server pid
+	Process string        `yaml:"process" json:"process"`   // process name, e.g. sshd
+	Command string        `yaml:"command" json:"command"`   // shell command, e.g. systemctl reload sshd
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`   // limit for Command (default 30s)
+}
+
+// enabled reports whether a reload target is configured.
+func (c reloadConfig) enabled() bool {
+	return c.PidFile != "" || c.Process != "" || c.Command != ""
+}
+
+// validate checks that exactly one reload target is configured and that the
+// signal name is known.
+func (c reloadConfig) validate() error {
+	targets := 0
+	for _, t := range []string{c.PidFile, c.Process, c.Command} {
+		if t != "" {
+			targets++
+		}
+	}
+	if targets > 1 {
+		return errors.New("reload: use only one of pid_file, process and command")
+	}
+	if c.Signal != "" {
+		if c.Command != "" {
+			return errors.New("reload: signal cannot be combined with command")
+		}
+		if _, err := parseSignal(c.Signal); err != nil {
+			return fmt.Errorf("reload: %w", err)
+		}
+	}
+	return nil
+}
+
+// runReloadHook performs the configured reload and returns a summary for
+// display.
+func runReloadHook(c reloadConfig) (string, error) {
+	if c.Command != "" {
+		return runReloadCommand(c.Command, c.Timeout)
+	}
+
+	name := c.Signal
+	if name == "" {
+		name = defaultReloadSignal
+	}
+	sig, err := parseSignal(name)
+	if err != nil {
+		return "", err
+	}
+	var pid int
+	if c.PidFile != "" {
+		if pid, err = readPidFile(c.PidFile); err != nil {
+			return "", err
+		}
+	} else {
+		if pid, err = findProcess(c.Process); err != nil {
+			return "", err
+		}
+		if pid == 0 {
+			return "", fmt.Errorf("no running process named %q", c.Process)
+		}
+	}
+
+	p, err := os.FindProcess(pid)
+	if err == nil {
+		err = p.Signal(sig)
+	}
+	if err != nil {
+		return "", fmt.Errorf("sending %s to pid %d: %w", sig, pid, err)
+	}
+	return fmt.Sprintf("sent %s to pid %d", signalName(name), pid), nil
+}
+
+// readPidFile reads the process id stored in a pid file.
+func readPidFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("%s: no process id found", path)
+	}
+	return pid, nil
+}
+
+// runReloadCommand runs command through the system shell and returns its
+// trimmed output.
+func runReloadCommand(command string, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	}
+	out, err := cmd.CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("%q timed out after %s", command, timeout)
+	}
+	if err != nil {
+		if output != "" {
+			return "", fmt.Errorf("%q: %v: %s", command, err, output)
+		}
+		return "", fmt.Errorf("%q: %w", command, err)
+	}
+	if output != "" {
+		return fmt.Sprintf("ran %q: %s", command, output), nil
+	}
+	return fmt.Sprintf("ran %q", command), nil
+}
+
+// signalName returns the conventional SIG-prefixed name of a signal.
+func signalName(name string) string {
+	name = strings.ToUpper(name)
+	if strings.HasPrefix(name, "SIG") {
+		return name
+	}
+	return "SIG" + name
+}
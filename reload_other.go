@@ -0,0 +1,38 @@
+//go:build !unix
+
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : reload_other.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 22:42:20
+ * Description  : Reload hook on systems without Unix signals
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// Only a reload command can be used where processes cannot be signalled
+var errNoSignals = errors.New("signals are not supported on this system; configure a reload command instead")
+
+// parseSignal always fails: there are no Unix signals to send.
+func parseSignal(name string) (os.Signal, error) {
+	return nil, errNoSignals
+}
+
+// findProcess always fails: processes cannot be signalled.
+func findProcess(name string) (int, error) {
+	return 0, errNoSignals
+}
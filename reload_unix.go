@@ -0,0 +1,131 @@
+//go:build unix
+
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : reload_unix.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 22:42:02
+ * Description  : Signals and process lookup on Unix systems
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Signals that may be configured for the reload hook
+var reloadSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// parseSignal turns a name such as HUP or SIGHUP into a signal.
+func parseSignal(name string) (os.Signal, error) {
+	sig, ok := reloadSignals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return nil, fmt.Errorf("unknown signal %q (HUP, INT, QUIT, TERM, USR1 or USR2)", name)
+	}
+	return sig, nil
+}
+
+// findProcess returns the id of the oldest running process called name, or 0
+// when there is none. Daemons such as sshd fork a child per connection under
+// the same name; the oldest process is the listener that reloads its keys. It
+// reads /proc where available and falls back to pgrep elsewhere.
+func findProcess(name string) (int, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return pgrep(name)
+	}
+	self := os.Getpid()
+	oldest, oldestStart := 0, uint64(0)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == self || !processNamed(e.Name(), name) {
+			continue
+		}
+		start, err := processStartTime(e.Name())
+		if err != nil {
+			// the process exited while scanning
+			continue
+		}
+		if oldest == 0 || start < oldestStart || (start == oldestStart && pid < oldest) {
+			oldest, oldestStart = pid, start
+		}
+	}
+	return oldest, nil
+}
+
+// processNamed reports whether the /proc entry dir runs a program called name.
+func processNamed(dir, name string) bool {
+	if comm, err := os.ReadFile(filepath.Join("/proc", dir, "comm")); err == nil && strings.TrimSpace(string(comm)) == name {
+		return true
+	}
+	// comm is cut to 15 characters, so also compare the program path
+	cmdline, err := os.ReadFile(filepath.Join("/proc", dir, "cmdline"))
+	if err != nil || len(cmdline) == 0 {
+		return false
+	}
+	argv0, _, _ := bytes.Cut(cmdline, []byte{0})
+	return filepath.Base(string(argv0)) == name
+}
+
+// processStartTime returns the start time of a process in clock ticks since
+// boot, field 22 of /proc/<pid>/stat.
+func processStartTime(dir string) (uint64, error) {
+	stat, err := os.ReadFile(filepath.Join("/proc", dir, "stat"))
+	if err != nil {
+		return 0, err
+	}
+	// the command name in field 2 may contain spaces; skip past it
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, fmt.Errorf("malformed /proc/%s/stat", dir)
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("malformed /proc/%s/stat", dir)
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// pgrep looks up the oldest process with the given name with the pgrep
+// utility.
+func pgrep(name string) (int, error) {
+	out, err := exec.Command("pgrep", "-o", "-x", name).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// no matching process
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("pgrep: %w", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return 0, fmt.Errorf("pgrep: unexpected output %q", out)
+	}
+	return pid, nil
+}
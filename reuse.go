@@ -0,0 +1,281 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : reuse.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 19:52:40
+ * Description  : Reused key and orphaned public key analysis over inventories
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Roles of a key
+const (
+	roleHost = "host"
+	roleUser = "user"
+)
+
+// Host key file names, e.g. ssh_host_ed25519_key and ssh_host_rsa_key.pub
+var hostKeyName = regexp.MustCompile(`^ssh_host_[a-z0-9_]+_key(\.pub)?$`)
+
+// One key found in more than one place
+type reusedKey struct {
+	Fingerprint string   `json:"fingerprint"`
+	Algorithm   string   `json:"algorithm"`
+	Role        string   `json:"role"`
+	Severity    string   `json:"severity"`
+	Hosts       []string `json:"hosts"`
+	Locations   []string `json:"locations"`
+}
+
+// A .pub file without a matching private key
+type orphanedKey struct {
+	Host        string `json:"host"`
+	Path        string `json:"path"`
+	Fingerprint string `json:"fingerprint"`
+	Severity    string `json:"severity"`
+	Reason      string `json:"reason"`
+}
+
+// Result of the analysis
+type reuseReport struct {
+	Reused   []reusedKey   `json:"reused"`
+	Orphaned []orphanedKey `json:"orphaned"`
+}
+
+// keyRole tells host keys from user keys by their file name.
+func keyRole(path string) string {
+	if hostKeyName.MatchString(filepath.Base(path)) {
+		return roleHost
+	}
+	return roleUser
+}
+
+// analyzeReuse groups private and public key files by fingerprint across
+// paths and hosts. A host key is reused when it appears on more than one host
+// or in more than one private key file; a user key when its private half is
+// stored in more than one place. Authorized_keys entries and certificates are
+// not counted, since the same user key is expected to be authorized widely.
+func analyzeReuse(items []inventoryItem) reuseReport {
+	var report reuseReport
+
+	groups := map[string][]inventoryItem{}
+	var order []string
+	for _, it := range items {
+		if it.Fingerprint == "" || (it.Kind != kindPrivate && it.Kind != kindPublic) {
+			continue
+		}
+		if _, ok := groups[it.Fingerprint]; !ok {
+			order = append(order, it.Fingerprint)
+		}
+		groups[it.Fingerprint] = append(groups[it.Fingerprint], it)
+	}
+
+	for _, fingerprint := range order {
+		group := groups[fingerprint]
+		role := roleUser
+		var hosts, locations []string
+		privates := 0
+		for _, it := range group {
+			if keyRole(it.Path) == roleHost {
+				role = roleHost
+			}
+			if !containsString(hosts, it.Host) {
+				hosts = append(hosts, it.Host)
+			}
+			if it.Kind == kindPrivate {
+				privates++
+			}
+			locations = append(locations, it.Host+":"+it.Path)
+		}
+
+		reused := privates > 1
+		severity := severityWarning
+		if role == roleHost {
+			reused = reused || len(hosts) > 1
+			severity = severityError
+		}
+		if !reused {
+			continue
+		}
+		sort.Strings(hosts)
+		sort.Strings(locations)
+		report.Reused = append(report.Reused, reusedKey{
+			Fingerprint: fingerprint,
+			Algorithm:   group[0].Algorithm,
+			Role:        role,
+			Severity:    severity,
+			Hosts:       hosts,
+			Locations:   locations,
+		})
+	}
+	sort.SliceStable(report.Reused, func(i, j int) bool {
+		if report.Reused[i].Role != report.Reused[j].Role {
+			return report.Reused[i].Role == roleHost
+		}
+		return len(report.Reused[i].Locations) > len(report.Reused[j].Locations)
+	})
+
+	// Orphaned .pub files: look up <name> next to each <name>.pub
+	privates := map[string]inventoryItem{}
+	for _, it := range items {
+		if it.Kind == kindPrivate {
+			privates[it.Host+"\x00"+it.Path] = it
+		}
+	}
+	for _, it := range items {
+		if it.Kind != kindPublic || !strings.HasSuffix(it.Path, ".pub") {
+			continue
+		}
+		privPath := strings.TrimSuffix(it.Path, ".pub")
+		priv, ok := privates[it.Host+"\x00"+privPath]
+		switch {
+		case !ok:
+			report.Orphaned = append(report.Orphaned, orphanedKey{
+				Host: it.Host, Path: it.Path, Fingerprint: it.Fingerprint, Severity: severityWarning,
+				Reason: "private key " + filepath.Base(privPath) + " not found",
+			})
+		case priv.Fingerprint != "" && priv.Fingerprint != it.Fingerprint:
+			report.Orphaned = append(report.Orphaned, orphanedKey{
+				Host: it.Host, Path: it.Path, Fingerprint: it.Fingerprint, Severity: severityError,
+				Reason: "does not match private key " + filepath.Base(privPath) + " (" + priv.Fingerprint + ")",
+			})
+		}
+	}
+	sort.SliceStable(report.Orphaned, func(i, j int) bool {
+		if report.Orphaned[i].Host != report.Orphaned[j].Host {
+			return report.Orphaned[i].Host < report.Orphaned[j].Host
+		}
+		return report.Orphaned[i].Path < report.Orphaned[j].Path
+	})
+	return report
+}
+
+// loadInventories scans directories and files, or reads saved inventories
+// for arguments ending in .json.
+func loadInventories(args []string, host string, policy scanPolicy, warn func(error)) ([]inventoryItem, error) {
+	var items []inventoryItem
+	var roots []string
+	for _, arg := range args {
+		if strings.EqualFold(filepath.Ext(arg), ".json") {
+			inv, err := readInventory(arg)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, inv.Items...)
+			continue
+		}
+		roots = append(roots, arg)
+	}
+	if len(roots) > 0 {
+		items = append(items, scanPaths(roots, host, policy, warn).Items...)
+	}
+	return items, nil
+}
+
+// Run the reuse subcommand
+func runReuse(args []string) error {
+	fs := flag.NewFlagSet("reuse", flag.ExitOnError)
+	format := fs.String("format", "text", "output format: text or json")
+	host := fs.String("host", "", "host name recorded for scanned paths (default: this host)")
+	quiet := fs.Bool("q", false, "do not print unreadable paths")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s reuse [flags] <inventory.json|dir|file>... (default: ~/.ssh)\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	inputs := fs.Args()
+	if len(inputs) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		inputs = []string{filepath.Join(home, ".ssh")}
+	}
+	if *host == "" {
+		*host, _ = os.Hostname()
+	}
+	warn := func(err error) {
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
+	items, err := loadInventories(inputs, *host, scanPolicy{}, warn)
+	if err != nil {
+		return err
+	}
+	report := analyzeReuse(items)
+
+	switch *format {
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	case "text":
+		printReuseReport(report)
+	default:
+		return fmt.Errorf("unknown format %q (text or json)", *format)
+	}
+
+	errorsFound := 0
+	for _, r := range report.Reused {
+		if r.Severity == severityError {
+			errorsFound++
+		}
+	}
+	for _, o := range report.Orphaned {
+		if o.Severity == severityError {
+			errorsFound++
+		}
+	}
+	if errorsFound > 0 {
+		return fmt.Errorf("%d errors found", errorsFound)
+	}
+	return nil
+}
+
+// printReuseReport prints reused keys with their locations, then orphans.
+func printReuseReport(report reuseReport) {
+	if len(report.Reused) == 0 && len(report.Orphaned) == 0 {
+		fmt.Println("No reused keys or orphaned public keys found")
+		return
+	}
+	render := func(severity string) string {
+		if severity == severityError {
+			return errorStyle.Render(severity + ":")
+		}
+		return warningStyle.Render(severity + ":")
+	}
+	for _, r := range report.Reused {
+		fmt.Printf("%s reused %s key %s %s on %d hosts, %d files\n",
+			render(r.Severity), r.Role, r.Algorithm, r.Fingerprint, len(r.Hosts), len(r.Locations))
+		for _, loc := range r.Locations {
+			fmt.Printf("    %s\n", loc)
+		}
+	}
+	for _, o := range report.Orphaned {
+		fmt.Printf("%s orphaned public key %s:%s: %s\n", render(o.Severity), o.Host, o.Path, o.Reason)
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : rfc4716.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 15:41:07
+ * Description  : RFC 4716 (SSH2) public key export and import
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// RFC 4716 constants
+const (
+	rfc4716Begin      = "---- BEGIN SSH2 PUBLIC KEY ----"
+	rfc4716End        = "---- END SSH2 PUBLIC KEY ----"
+	rfc4716LineLength = 72 // including a header continuation backslash
+	rfc4716MaxTag     = 64
+)
+
+// A public key read from an RFC 4716 file
+type rfc4716Key struct {
+	key     ssh.PublicKey
+	comment string
+	subject string
+}
+
+// marshalRFC4716 encodes a public key with optional Subject and Comment
+// headers. Every line, including continued headers, fits in 72 columns.
+func marshalRFC4716(pub ssh.PublicKey, subject, comment string) []byte {
+	var b bytes.Buffer
+	b.WriteString(rfc4716Begin + "\n")
+	if subject != "" {
+		writeRFC4716Header(&b, "Subject", subject)
+	}
+	if comment != "" {
+		writeRFC4716Header(&b, "Comment", `"`+strings.ReplaceAll(comment, `"`, `\"`)+`"`)
+	}
+	encoded := base64.StdEncoding.EncodeToString(pub.Marshal())
+	for len(encoded) > rfc4716LineLength {
+		b.WriteString(encoded[:rfc4716LineLength] + "\n")
+		encoded = encoded[rfc4716LineLength:]
+	}
+	b.WriteString(encoded + "\n")
+	b.WriteString(rfc4716End + "\n")
+	return b.Bytes()
+}
+
+// writeRFC4716Header writes "Tag: value", continuing long values on the
+// next line with a trailing backslash.
+func writeRFC4716Header(b *bytes.Buffer, tag, value string) {
+	line := tag + ": " + value
+	for len(line) > rfc4716LineLength {
+		b.WriteString(line[:rfc4716LineLength-1] + "\\\n")
+		line = line[rfc4716LineLength-1:]
+	}
+	b.WriteString(line + "\n")
+}
+
+// parseRFC4716 decodes every SSH2 public key block in data.
+func parseRFC4716(data []byte) ([]rfc4716Key, error) {
+	// Lines are trimmed as they are read; continuation lines keep their
+	// leading whitespace, which may fall where a header value was wrapped.
+	lines := strings.Split(string(data), "\n")
+	var keys []rfc4716Key
+
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != rfc4716Begin {
+			continue
+		}
+		n := len(keys) + 1
+		var k rfc4716Key
+		var body strings.Builder
+		inHeaders, closed := true, false
+
+		for i++; i < len(lines); i++ {
+			line := strings.TrimSpace(lines[i])
+			if line == "" {
+				continue
+			}
+			if line == rfc4716End {
+				closed = true
+				break
+			}
+			if inHeaders && strings.Contains(line, ":") {
+				// Join continuation lines
+				for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+					i++
+					line = strings.TrimSuffix(line, "\\") + strings.TrimRight(lines[i], " \t\r")
+				}
+				tag, value, _ := strings.Cut(line, ":")
+				if len(tag) == 0 || len(tag) > rfc4716MaxTag {
+					return nil, fmt.Errorf("key %d: invalid header tag %q", n, tag)
+				}
+				value = unquoteRFC4716Value(strings.TrimSpace(value))
+				switch strings.ToLower(tag) {
+				case "comment":
+					k.comment = value
+				case "subject":
+					k.subject = value
+				}
+				continue
+			}
+			inHeaders = false
+			body.WriteString(line)
+		}
+		if !closed {
+			return nil, fmt.Errorf("key %d: missing %q", n, rfc4716End)
+		}
+
+		blob, err := base64.StdEncoding.DecodeString(body.String())
+		if err != nil {
+			return nil, fmt.Errorf("key %d: invalid key data: %w", n, err)
+		}
+		if k.key, err = ssh.ParsePublicKey(blob); err != nil {
+			return nil, fmt.Errorf("key %d: %w", n, err)
+		}
+		keys = append(keys, k)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no SSH2 public key found")
+	}
+	return keys, nil
+}
+
+// unquoteRFC4716Value strips the optional double quotes around a header value.
+func unquoteRFC4716Value(value string) string {
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		return strings.ReplaceAll(value[1:len(value)-1], `\"`, `"`)
+	}
+	return value
+}
+
+// exportRFC4716 prints the public key of path in RFC 4716 format. path may be
+// a public key or a private key (its .pub is used when present).
+func exportRFC4716(path, subject, comment string) error {
+	pub, fileComment, err := loadPublicKey(path)
+	if err != nil {
+		if _, statErr := os.Stat(path + ".pub"); statErr == nil {
+			pub, fileComment, err = loadPublicKey(path + ".pub")
+		} else {
+			var priv interface{}
+			if priv, err = loadPrivateKey(path, ""); err == nil {
+				var signer ssh.Signer
+				if signer, err = ssh.NewSignerFromKey(priv); err == nil {
+					pub = signer.PublicKey()
+				}
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if comment == "" {
+		comment = fileComment
+	}
+	if comment == "" {
+		comment = fmt.Sprintf("%d-bit %s", publicKeyBits(pub), pub.Type())
+	}
+	_, err = os.Stdout.Write(marshalRFC4716(pub, subject, comment))
+	return err
+}
+
+// importRFC4716 prints the keys of an RFC 4716 file as authorized_keys lines.
+func importRFC4716(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	keys, err := parseRFC4716(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, k := range keys {
+		line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(k.key)))
+		if k.comment != "" {
+			line += " " + k.comment
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : rfc4716_test.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 23:02:44
+ * Description  : Tests for RFC 4716 (SSH2) public key files
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestRFC4716HeaderWrappedAtSpace(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// `Comment: "` plus 61 characters fills the first line, so the
+	// continuation line starts with the space
+	comment := strings.Repeat("a", 61) + " bob"
+	subject := strings.Repeat("s", 62) + "  two spaces"
+	data := marshalRFC4716(signer.PublicKey(), subject, comment)
+	if !strings.Contains(string(data), "\\\n bob") {
+		t.Fatalf("comment not wrapped at the space:\n%s", data)
+	}
+
+	keys, err := parseRFC4716(data)
+	if err != nil {
+		t.Fatalf("parseRFC4716: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("parsed %d keys, want 1", len(keys))
+	}
+	if keys[0].comment != comment {
+		t.Errorf("comment = %q, want %q", keys[0].comment, comment)
+	}
+	if keys[0].subject != subject {
+		t.Errorf("subject = %q, want %q", keys[0].subject, subject)
+	}
+	if !keysEqual(keys[0].key, signer.PublicKey()) {
+		t.Error("key changed in the round trip")
+	}
+}
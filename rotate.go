@@ -0,0 +1,596 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : rotate.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 22:41:06
+ * Description  : Host key rotation with an overlap period
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Rotation states
+const (
+	rotationPending  = "pending"  // successor generated, not yet in use
+	rotationPromoted = "promoted" // successor in use, previous key kept
+	rotationRetired  = "retired"  // previous key removed
+)
+
+// File name suffixes used during a rotation
+const (
+	rotationNextSuffix  = ".next"
+	rotationOldSuffix   = ".old"
+	rotationStateSuffix = ".rotation.json"
+)
+
+// SSHFP algorithm numbers (RFC 4255, 6594, 7479)
+var sshfpAlgorithms = map[string]int{
+	ssh.KeyAlgoRSA:      1,
+	ssh.KeyAlgoDSA:      2,
+	ssh.KeyAlgoECDSA256: 3,
+	ssh.KeyAlgoECDSA384: 3,
+	ssh.KeyAlgoECDSA521: 3,
+	ssh.KeyAlgoED25519:  4,
+}
+
+// One key taking part in a rotation
+type rotationKey struct {
+	Path        string    `json:"path,omitempty"` // empty once retired
+	Algorithm   string    `json:"algorithm"`
+	Bits        int       `json:"bits"`
+	Fingerprint string    `json:"fingerprint"`
+	PublicKey   string    `json:"public_key"`
+	Since       time.Time `json:"since"`
+}
+
+// Rotation metadata, stored in <key>.rotation.json
+type rotationState struct {
+	Key        string       `json:"key"`
+	State      string       `json:"state"`
+	Current    *rotationKey `json:"current"`
+	Successor  *rotationKey `json:"successor,omitempty"`
+	Previous   *rotationKey `json:"previous,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	PromotedAt *time.Time   `json:"promoted_at,omitempty"`
+	RetiredAt  *time.Time   `json:"retired_at,omitempty"`
+}
+
+// readRotationState loads the metadata of key, or returns nil when no
+// rotation was ever started.
+func readRotationState(key string) (*rotationState, error) {
+	data, err := os.ReadFile(key + rotationStateSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var st rotationState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("%s: %w", key+rotationStateSuffix, err)
+	}
+	return &st, nil
+}
+
+// write saves the metadata next to the key.
+func (st *rotationState) write() error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(st.Key+rotationStateSuffix, append(data, '\n'), 0o644)
+}
+
+// newRotationKey describes the public key of the pair at path.
+func newRotationKey(path string, pub ssh.PublicKey) *rotationKey {
+	return &rotationKey{
+		Path:        path,
+		Algorithm:   pub.Type(),
+		Bits:        publicKeyBits(pub),
+		Fingerprint: ssh.FingerprintSHA256(pub),
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))),
+		Since:       time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+// publicKey parses the recorded public key.
+func (k *rotationKey) publicKey() (ssh.PublicKey, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.PublicKey))
+	return pub, err
+}
+
+// checkOnDisk makes sure the key pair at path is still the recorded one.
+func (k *rotationKey) checkOnDisk(path string) error {
+	if _, err := selfTestKeyPair(path, path+".pub", nil); err != nil {
+		return err
+	}
+	pub, _, err := loadPublicKey(path + ".pub")
+	if err != nil {
+		return err
+	}
+	if fp := ssh.FingerprintSHA256(pub); fp != k.Fingerprint {
+		return fmt.Errorf("%s holds %s, but the rotation metadata records %s", path, fp, k.Fingerprint)
+	}
+	return nil
+}
+
+// renameKeyPair moves a private key and its .pub file.
+func renameKeyPair(from, to string) error {
+	if err := os.Rename(from, to); err != nil {
+		return err
+	}
+	if err := os.Rename(from+".pub", to+".pub"); err != nil {
+		_ = os.Rename(to, from)
+		return err
+	}
+	return nil
+}
+
+// undoPromote moves the promoted key back to <key>.next and the previous key
+// back to <key>, and restores the pending metadata, after a promotion failed
+// once the files were swapped.
+func undoPromote(err error, key string, state keyFileBackup) error {
+	undoErr := renameKeyPair(key, key+rotationNextSuffix)
+	if undoErr == nil {
+		undoErr = renameKeyPair(key+rotationOldSuffix, key)
+	}
+	if undoErr == nil {
+		undoErr = state.restore()
+	}
+	if undoErr != nil {
+		return fmt.Errorf("%w; undoing the promotion failed: %v", err, undoErr)
+	}
+	return fmt.Errorf("%w; promotion undone", err)
+}
+
+// algorithmForKeyType maps an SSH key type to the generator's algorithm.
+func algorithmForKeyType(keyType string) (string, error) {
+	switch {
+	case keyType == ssh.KeyAlgoRSA:
+		return AlgorithmRSA, nil
+	case keyType == ssh.KeyAlgoED25519:
+		return AlgorithmED25519, nil
+	case strings.HasPrefix(keyType, "ecdsa-sha2-"):
+		return AlgorithmECDSA, nil
+	default:
+		return "", fmt.Errorf("cannot rotate %s keys", keyType)
+	}
+}
+
+// sshfpRecord returns the SHA-256 SSHFP resource record of a host key.
+func sshfpRecord(host string, pub ssh.PublicKey) (string, error) {
+	alg, ok := sshfpAlgorithms[pub.Type()]
+	if !ok {
+		return "", fmt.Errorf("no SSHFP algorithm for %s", pub.Type())
+	}
+	sum := sha256.Sum256(pub.Marshal())
+	return fmt.Sprintf("%s IN SSHFP %d 2 %x", strings.TrimSuffix(host, ".")+".", alg, sum), nil
+}
+
+// Run the rotate subcommand
+func runRotate(args []string) error {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s rotate <start|publish|promote|retire|abort|status> -f <key> [flags]\n", filepath.Base(os.Args[0]))
+	}
+	if len(args) == 0 {
+		usage()
+		return errors.New("missing rotate action")
+	}
+
+	switch args[0] {
+	case "start":
+		return runRotateStart(args[1:])
+	case "publish":
+		return runRotatePublish(args[1:])
+	case "promote":
+		return runRotatePromote(args[1:])
+	case "retire":
+		return runRotateRetire(args[1:])
+	case "abort":
+		return runRotateAbort(args[1:])
+	case "status":
+		return runRotateStatus(args[1:])
+	default:
+		usage()
+		return fmt.Errorf("unknown rotate action %q", args[0])
+	}
+}
+
+// Run rotate start: generate the successor next to the current key
+func runRotateStart(args []string) error {
+	fs := flag.NewFlagSet("rotate start", flag.ExitOnError)
+	key := fs.String("f", "id_rsa", "host private key currently in use")
+	algorithmName := fs.String("t", "", "algorithm of the successor: rsa, ed25519 or ecdsa (default: same as the current key)")
+	bits := fs.Int("b", 0, "key size of the successor (default: same as the current key)")
+	comment := fs.String("C", "", "comment of the successor (default: the current comment)")
+	configPath := fs.String("config", "", "configuration file with generation hooks (default: $"+configEnv+" or the user config directory)")
+	fs.Parse(args)
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	st, err := readRotationState(*key)
+	if err != nil {
+		return err
+	}
+	if st != nil && st.State != rotationRetired {
+		return fmt.Errorf("a rotation of %s is already %s (promote, retire or abort it first)", *key, st.State)
+	}
+
+	current, currentComment, err := loadPublicKey(*key + ".pub")
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(*key)
+	if err != nil {
+		return err
+	}
+	if *comment == "" {
+		*comment = currentComment
+	}
+
+	algorithm := ""
+	if *algorithmName != "" {
+		if algorithm, err = parseAlgorithm(*algorithmName); err != nil {
+			return err
+		}
+	} else if algorithm, err = algorithmForKeyType(current.Type()); err != nil {
+		return err
+	}
+	if *bits == 0 {
+		if *algorithmName == "" {
+			*bits = publicKeyBits(current)
+		} else {
+			info, _ := algorithmInfo(algorithm)
+			*bits = info.DefaultSize
+		}
+	}
+	if err := validateKeySize(algorithm, *bits); err != nil {
+		return err
+	}
+
+	next := *key + rotationNextSuffix
+	if fileExists(next) || fileExists(next+".pub") {
+		return fmt.Errorf("%s already exists", next)
+	}
+	priv, err := generatePrivateKey(algorithm, *bits)
+	if err != nil {
+		return err
+	}
+	// Keep the file format of the current key
+	var privData []byte
+	if block, _ := pem.Decode(data); block != nil && block.Type == "OPENSSH PRIVATE KEY" {
+		privData, err = marshalOpenSSHPrivateKey(priv, *comment, nil, "", 0)
+	} else {
+		privData, err = encodePrivateKeyToPEM(priv, algorithm)
+	}
+	if err != nil {
+		return err
+	}
+	pubData, err := publicKeySSHPublicKey(priv, algorithm, *comment)
+	if err != nil {
+		return err
+	}
+	// The successor did not exist before, so a rollback removes it. The
+	// metadata of a retired rotation is put back.
+	backups := []keyFileBackup{{path: next}, {path: next + ".pub"}}
+	stateBackup, err := backupKeyFile(*key + rotationStateSuffix)
+	if err != nil {
+		return err
+	}
+	if err := writeKeyPair(next, next+".pub", privData, pubData, backups...); err != nil {
+		return err
+	}
+	if _, err := selfTestKeyPair(next, next+".pub", nil); err != nil {
+		return rollbackKeyFiles(err, backups...)
+	}
+	successor, _, err := loadPublicKey(next + ".pub")
+	if err != nil {
+		return rollbackKeyFiles(err, backups...)
+	}
+
+	st = &rotationState{
+		Key:       *key,
+		State:     rotationPending,
+		Current:   newRotationKey(*key, current),
+		Successor: newRotationKey(next, successor),
+		StartedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := st.write(); err != nil {
+		return rollbackKeyFiles(err, append(backups, stateBackup)...)
+	}
+	hooks, hookWarnings, err := runWrittenKeyHooks(config, next, next+".pub", backups...)
+	if err != nil {
+		if restoreErr := stateBackup.restore(); restoreErr != nil {
+			return fmt.Errorf("%w; restoring %s: %v", err, stateBackup.path, restoreErr)
+		}
+		return err
+	}
+	fmt.Printf("Successor %s %s written to %s (pending)\n", successor.Type(), ssh.FingerprintSHA256(successor), next)
+	for _, h := range hooks {
+		fmt.Printf("Hook: %s\n", h)
+	}
+	for _, w := range hookWarnings {
+		fmt.Fprintf(os.Stderr, "warning: hook failed: %s\n", w)
+	}
+	fmt.Printf("Next: publish both keys with 'rotate publish -f %s', then promote once clients know the new key\n", *key)
+	return nil
+}
+
+// Run rotate publish: print known_hosts lines and SSHFP records
+func runRotatePublish(args []string) error {
+	fs := flag.NewFlagSet("rotate publish", flag.ExitOnError)
+	key := fs.String("f", "id_rsa", "host private key being rotated")
+	var hosts stringList
+	fs.Var(&hosts, "host", "host name clients use, repeatable (default: this host)")
+	port := fs.Int("p", 22, "SSH port clients connect to")
+	fs.Parse(args)
+
+	st, err := readRotationState(*key)
+	if err != nil {
+		return err
+	}
+	if st == nil {
+		return fmt.Errorf("no rotation started for %s", *key)
+	}
+	if len(hosts) == 0 {
+		name, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		hosts = stringList{name}
+	}
+
+	// During the overlap both keys must be trusted
+	keys := []*rotationKey{st.Current}
+	switch st.State {
+	case rotationPending:
+		keys = append(keys, st.Successor)
+	case rotationPromoted:
+		keys = append(keys, st.Previous)
+	}
+
+	var addresses []string
+	for _, h := range hosts {
+		addresses = append(addresses, knownhosts.Normalize(net.JoinHostPort(h, strconv.Itoa(*port))))
+	}
+	var records []string
+	fmt.Printf("# known_hosts (%s)\n", st.State)
+	for _, k := range keys {
+		pub, err := k.publicKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(knownhosts.Line(addresses, pub))
+		for _, h := range hosts {
+			// SSHFP records belong to DNS names only
+			if net.ParseIP(h) != nil {
+				continue
+			}
+			record, err := sshfpRecord(h, pub)
+			if err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+	}
+	fmt.Println("# DNS SSHFP records")
+	for _, r := range records {
+		fmt.Println(r)
+	}
+	return nil
+}
+
+// Run rotate promote: the successor becomes the key in use
+func runRotatePromote(args []string) error {
+	fs := flag.NewFlagSet("rotate promote", flag.ExitOnError)
+	key := fs.String("f", "id_rsa", "host private key being rotated")
+	configPath := fs.String("config", "", "configuration file with the reload hook (default: $"+configEnv+" or the user config directory)")
+	noReload := fs.Bool("no-reload", false, "do not run the reload hook of the configuration file")
+	fs.Parse(args)
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	st, err := readRotationState(*key)
+	if err != nil {
+		return err
+	}
+	if st == nil || st.State != rotationPending {
+		return fmt.Errorf("no pending rotation for %s", *key)
+	}
+	next, old := *key+rotationNextSuffix, *key+rotationOldSuffix
+	if err := st.Current.checkOnDisk(*key); err != nil {
+		return err
+	}
+	if err := st.Successor.checkOnDisk(next); err != nil {
+		return err
+	}
+	if fileExists(old) || fileExists(old+".pub") {
+		return fmt.Errorf("%s already exists", old)
+	}
+
+	stateBackup, err := backupKeyFile(*key + rotationStateSuffix)
+	if err != nil {
+		return err
+	}
+	if err := renameKeyPair(*key, old); err != nil {
+		return err
+	}
+	if err := renameKeyPair(next, *key); err != nil {
+		_ = renameKeyPair(old, *key)
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	st.Previous, st.Current, st.Successor = st.Current, st.Successor, nil
+	st.Previous.Path, st.Current.Path = old, *key
+	st.Current.Since = now
+	st.State, st.PromotedAt = rotationPromoted, &now
+	if err := st.write(); err != nil {
+		return undoPromote(err, *key, stateBackup)
+	}
+	pub, err := st.Current.publicKey()
+	if err != nil {
+		return undoPromote(err, *key, stateBackup)
+	}
+	if err := logKeyEvent(config, newAuditRecord(auditOverwritten, pub, *key, *key+".pub")); err != nil {
+		return undoPromote(err, *key, stateBackup)
+	}
+	fmt.Printf("%s now holds %s; the previous key was moved to %s\n", *key, st.Current.Fingerprint, old)
+	if *noReload || !config.Reload.enabled() {
+		fmt.Println("Reload the server so it presents the new key, then retire the old one once clients have switched")
+		return nil
+	}
+	result, err := runReloadHook(config.Reload)
+	if err != nil {
+		return fmt.Errorf("server reload failed: %w", err)
+	}
+	fmt.Printf("Server reloaded: %s\n", result)
+	fmt.Println("Retire the old key once clients have switched")
+	return nil
+}
+
+// Run rotate retire: remove the previous key
+func runRotateRetire(args []string) error {
+	fs := flag.NewFlagSet("rotate retire", flag.ExitOnError)
+	key := fs.String("f", "id_rsa", "host private key being rotated")
+	configPath := fs.String("config", "", "configuration file with the audit log (default: $"+configEnv+" or the user config directory)")
+	fs.Parse(args)
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	st, err := readRotationState(*key)
+	if err != nil {
+		return err
+	}
+	if st == nil || st.State != rotationPromoted {
+		return fmt.Errorf("no promoted rotation for %s", *key)
+	}
+	old := *key + rotationOldSuffix
+	if err := st.Previous.checkOnDisk(old); err != nil {
+		return err
+	}
+	if err := os.Remove(old); err != nil {
+		return err
+	}
+	if err := os.Remove(old + ".pub"); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	st.Previous.Path = ""
+	st.State, st.RetiredAt = rotationRetired, &now
+	if err := st.write(); err != nil {
+		return err
+	}
+	pub, err := st.Previous.publicKey()
+	if err != nil {
+		return err
+	}
+	if err := logKeyEvent(config, newAuditRecord(auditDeleted, pub, old, old+".pub")); err != nil {
+		return err
+	}
+	fmt.Printf("Retired %s; remove it from known_hosts and DNS\n", st.Previous.Fingerprint)
+	return nil
+}
+
+// Run rotate abort: drop a pending successor
+func runRotateAbort(args []string) error {
+	fs := flag.NewFlagSet("rotate abort", flag.ExitOnError)
+	key := fs.String("f", "id_rsa", "host private key being rotated")
+	configPath := fs.String("config", "", "configuration file with the audit log (default: $"+configEnv+" or the user config directory)")
+	fs.Parse(args)
+
+	config, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	st, err := readRotationState(*key)
+	if err != nil {
+		return err
+	}
+	if st == nil || st.State != rotationPending {
+		return fmt.Errorf("no pending rotation for %s", *key)
+	}
+	next := *key + rotationNextSuffix
+	for _, path := range []string{next, next + ".pub", *key + rotationStateSuffix} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	pub, err := st.Successor.publicKey()
+	if err != nil {
+		return err
+	}
+	if err := logKeyEvent(config, newAuditRecord(auditDeleted, pub, next, next+".pub")); err != nil {
+		return err
+	}
+	fmt.Printf("Rotation of %s aborted; %s removed\n", *key, next)
+	return nil
+}
+
+// Run rotate status
+func runRotateStatus(args []string) error {
+	fs := flag.NewFlagSet("rotate status", flag.ExitOnError)
+	key := fs.String("f", "id_rsa", "host private key")
+	fs.Parse(args)
+
+	st, err := readRotationState(*key)
+	if err != nil {
+		return err
+	}
+	if st == nil {
+		fmt.Printf("%s: no rotation started\n", *key)
+		return nil
+	}
+	fmt.Printf("%s: %s (started %s)\n", *key, st.State, st.StartedAt.Format(time.RFC3339))
+	for _, k := range []struct {
+		role string
+		key  *rotationKey
+	}{{"current", st.Current}, {"successor", st.Successor}, {"previous", st.Previous}} {
+		if k.key == nil {
+			continue
+		}
+		fmt.Printf("  %-9s  %s %d %s", k.role, k.key.Algorithm, k.key.Bits, k.key.Fingerprint)
+		if k.key.Path != "" {
+			fmt.Printf("  %s", k.key.Path)
+		}
+		fmt.Println()
+	}
+	return nil
+}
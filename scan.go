@@ -0,0 +1,596 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : scan.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 19:14:26
+ * Description  : Key inventory scanner for directories and .ssh trees
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Kinds of inventory items
+const (
+	kindPrivate        = "private"
+	kindPublic         = "public"
+	kindCertificate    = "certificate"
+	kindAuthorizedKeys = "authorized_keys"
+	kindKnownHosts     = "known_hosts"
+)
+
+// Files larger than this are not keys
+const scanMaxFileSize = 1 << 20
+
+// Directories never worth descending into
+var scanSkipDirs = []string{".git", ".hg", ".svn", "node_modules", "vendor"}
+
+// One key found by a scan
+type inventoryItem struct {
+	Host        string        `json:"host"`
+	Path        string        `json:"path"`
+	Line        int           `json:"line,omitempty"`
+	Kind        string        `json:"kind"`
+	Format      string        `json:"format"`
+	Algorithm   string        `json:"algorithm"`
+	Bits        int           `json:"bits,omitempty"`
+	Fingerprint string        `json:"fingerprint,omitempty"`
+	PublicKey   string        `json:"public_key,omitempty"`
+	Comment     string        `json:"comment,omitempty"`
+	Hosts       []string      `json:"hosts,omitempty"` // host patterns of known_hosts entries
+	Encrypted   bool          `json:"encrypted,omitempty"`
+	Mode        string        `json:"mode"`
+	Modified    time.Time     `json:"modified"`
+	AgeDays     int           `json:"age_days"`
+	NotAfter    *time.Time    `json:"not_after,omitempty"`
+	Pair        string        `json:"pair,omitempty"`
+	Problems    []string      `json:"problems,omitempty"`
+	key         ssh.PublicKey // nil when the key could not be read
+}
+
+// The result of a scan, also used as a snapshot
+type inventory struct {
+	Host      string          `json:"host"`
+	ScannedAt time.Time       `json:"scanned_at"`
+	Roots     []string        `json:"roots"`
+	Items     []inventoryItem `json:"items"`
+}
+
+// Scan policy
+type scanPolicy struct {
+	minRSABits int
+	maxAgeDays int
+}
+
+// scanPaths walks roots and returns every key found.
+func scanPaths(roots []string, host string, policy scanPolicy, warn func(error)) *inventory {
+	inv := &inventory{Host: host, ScannedAt: time.Now().UTC().Truncate(time.Second), Roots: roots}
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				warn(err)
+				if d != nil && d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				if path != root && containsString(scanSkipDirs, d.Name()) {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				warn(err)
+				return nil
+			}
+			if info.Size() == 0 || info.Size() > scanMaxFileSize {
+				return nil
+			}
+			items, err := scanFile(path, info)
+			if err != nil {
+				warn(err)
+				return nil
+			}
+			for i := range items {
+				items[i].Host = host
+			}
+			inv.Items = append(inv.Items, items...)
+			return nil
+		})
+		if err != nil {
+			warn(err)
+		}
+	}
+	matchKeyPairs(inv.Items)
+	for i := range inv.Items {
+		classifyItem(&inv.Items[i], policy)
+	}
+	return inv
+}
+
+// scanFile identifies the keys held by one file.
+func scanFile(path string, info fs.FileInfo) ([]inventoryItem, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	base := inventoryItem{
+		Path:     path,
+		Mode:     fmt.Sprintf("%04o", info.Mode().Perm()),
+		Modified: info.ModTime().UTC().Truncate(time.Second),
+	}
+	base.AgeDays = int(time.Since(info.ModTime()).Hours() / 24)
+
+	switch {
+	case bytes.HasPrefix(data, []byte("PuTTY-User-Key-File-")):
+		f, err := parsePPKFile(data)
+		if err != nil {
+			return nil, nil
+		}
+		item := base
+		item.Kind, item.Format = kindPrivate, fmt.Sprintf("ppk-v%d", f.version)
+		item.Comment, item.Encrypted = f.comment, f.encryption != ppkEncryptionNone
+		if pub, err := ssh.ParsePublicKey(f.public); err == nil {
+			item.setKey(pub)
+		}
+		return []inventoryItem{item}, nil
+
+	case bytes.Contains(data, []byte("-----BEGIN ")):
+		return scanPEMFile(base, data), nil
+	}
+	return scanTextFile(base, data), nil
+}
+
+// scanPEMFile handles private keys and X.509 certificates. In files with
+// several blocks, such as certificate chains, each item records the line its
+// block starts on, as entries of multi-key text files do.
+func scanPEMFile(base inventoryItem, data []byte) []inventoryItem {
+	var items []inventoryItem
+	rest := data
+	for {
+		start := len(data) - len(rest)
+		if i := bytes.Index(rest, []byte("-----BEGIN ")); i >= 0 {
+			start += i
+		}
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			if len(items) == 1 {
+				items[0].Line = 0
+			}
+			return items
+		}
+		item := base
+		item.Line = bytes.Count(data[:start], []byte("\n")) + 1
+		switch block.Type {
+		case "OPENSSH PRIVATE KEY":
+			item.Kind, item.Format = kindPrivate, "openssh"
+			w, err := parseOpenSSHContainer(pem.EncodeToMemory(block))
+			if err != nil {
+				continue
+			}
+			item.Encrypted = w.CipherName != "none"
+			if pub, err := ssh.ParsePublicKey(w.PubKey); err == nil {
+				item.setKey(pub)
+			}
+			if !item.Encrypted {
+				if info, err := readOpenSSHKeyInfo(pem.EncodeToMemory(block), nil); err == nil {
+					item.Comment = info.comment
+				}
+			}
+
+		case "RSA PRIVATE KEY", "EC PRIVATE KEY", "PRIVATE KEY", "ENCRYPTED PRIVATE KEY":
+			item.Kind, item.Format = kindPrivate, map[string]string{
+				"RSA PRIVATE KEY":       "pkcs1",
+				"EC PRIVATE KEY":        "sec1",
+				"PRIVATE KEY":           "pkcs8",
+				"ENCRYPTED PRIVATE KEY": "pkcs8",
+			}[block.Type]
+			k, err := decodeKey(pem.EncodeToMemory(block), func() ([]byte, error) {
+				return nil, errSkipEncrypted
+			})
+			switch {
+			case errors.Is(err, errSkipEncrypted):
+				item.Encrypted = true
+				item.Algorithm = map[string]string{"RSA PRIVATE KEY": ssh.KeyAlgoRSA, "EC PRIVATE KEY": "ecdsa"}[block.Type]
+				if item.Algorithm == "" {
+					item.Algorithm = "unknown"
+				}
+			case err != nil:
+				continue
+			default:
+				if pub, err := ssh.NewPublicKey(k.pub); err == nil {
+					item.setKey(pub)
+				}
+			}
+
+		case "CERTIFICATE":
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				continue
+			}
+			item.Kind, item.Format = kindCertificate, "x509"
+			item.Comment = cert.Subject.String()
+			notAfter := cert.NotAfter.UTC()
+			item.NotAfter = &notAfter
+			if pub, err := ssh.NewPublicKey(cert.PublicKey); err == nil {
+				item.setKey(pub)
+			} else {
+				item.Algorithm = strings.ToLower(cert.PublicKeyAlgorithm.String())
+			}
+
+		default:
+			continue
+		}
+		items = append(items, item)
+	}
+}
+
+// Returned by the passphrase callback to skip decrypting keys while scanning
+var errSkipEncrypted = errors.New("encrypted")
+
+// scanTextFile handles public keys, SSH certificates, authorized_keys and
+// known_hosts files.
+func scanTextFile(base inventoryItem, data []byte) []inventoryItem {
+	if isKnownHostsFile(filepath.Base(base.Path), data) {
+		return scanKnownHosts(base, data)
+	}
+	var items []inventoryItem
+	lines := strings.Split(string(data), "\n")
+	hasOptions := false
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pub, comment, options, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			if item, ok := rawKeyItem(base, line); ok {
+				item.Line = i + 1
+				items = append(items, item)
+				continue
+			}
+			// Not a key file, or a file we do not understand
+			if len(items) == 0 {
+				return nil
+			}
+			continue
+		}
+		item := base
+		item.Line = i + 1
+		item.Comment = comment
+		hasOptions = hasOptions || len(options) > 0
+		if cert, ok := pub.(*ssh.Certificate); ok {
+			item.Kind, item.Format = kindCertificate, "ssh-cert"
+			item.setKey(cert.Key)
+			if cert.ValidBefore != ssh.CertTimeInfinity {
+				notAfter := time.Unix(int64(cert.ValidBefore), 0).UTC()
+				item.NotAfter = &notAfter
+			}
+		} else {
+			item.Kind, item.Format = kindPublic, "ssh"
+			item.setKey(pub)
+		}
+		items = append(items, item)
+	}
+
+	name := filepath.Base(base.Path)
+	if strings.HasPrefix(name, "authorized_keys") || hasOptions || len(items) > 1 {
+		for i := range items {
+			if items[i].Kind == kindPublic {
+				items[i].Kind = kindAuthorizedKeys
+			}
+		}
+	} else if len(items) == 1 {
+		items[0].Line = 0
+	}
+	return items
+}
+
+// isKnownHostsFile reports whether a file holds known_hosts entries: it is
+// named like known_hosts or ssh_known_hosts, or its first entry starts with a
+// marker or a hashed host name, which authorized_keys lines never do.
+func isKnownHostsFile(name string, data []byte) bool {
+	if strings.Contains(name, "known_hosts") {
+		return true
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return strings.HasPrefix(line, "@cert-authority ") || strings.HasPrefix(line, "@revoked ") ||
+			strings.HasPrefix(line, "|1|")
+	}
+	return false
+}
+
+// scanKnownHosts lists the host keys of a known_hosts file. They belong to
+// other servers, so they are inventoried under their own kind.
+func scanKnownHosts(base inventoryItem, data []byte) []inventoryItem {
+	var items []inventoryItem
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		marker, hosts, pub, comment, _, err := ssh.ParseKnownHosts([]byte(line))
+		if err != nil {
+			continue
+		}
+		item := base
+		item.Line = i + 1
+		item.Kind, item.Format = kindKnownHosts, "known_hosts"
+		if marker != "" {
+			item.Format = marker
+		}
+		item.Hosts, item.Comment = hosts, comment
+		if cert, ok := pub.(*ssh.Certificate); ok {
+			pub = cert.Key
+		}
+		item.setKey(pub)
+		items = append(items, item)
+	}
+	return items
+}
+
+// setKey records the algorithm, size and fingerprint of a public key.
+func (it *inventoryItem) setKey(pub ssh.PublicKey) {
+	it.key = pub
+	it.Algorithm = pub.Type()
+	it.Bits = publicKeyBits(pub)
+	it.Fingerprint = ssh.FingerprintSHA256(pub)
+	it.PublicKey = strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+}
+
+// matchKeyPairs links private keys to public key files with the same
+// fingerprint, preferring <private>.pub.
+func matchKeyPairs(items []inventoryItem) {
+	publics := map[string][]int{}
+	for i, it := range items {
+		if it.Kind == kindPublic && it.Fingerprint != "" {
+			publics[it.Fingerprint] = append(publics[it.Fingerprint], i)
+		}
+	}
+	for i := range items {
+		priv := &items[i]
+		if priv.Kind != kindPrivate || priv.Fingerprint == "" {
+			continue
+		}
+		candidates := publics[priv.Fingerprint]
+		for _, j := range candidates {
+			if items[j].Path == priv.Path+".pub" && items[j].Host == priv.Host {
+				candidates = []int{j}
+				break
+			}
+		}
+		for _, j := range candidates {
+			if items[j].Host != priv.Host {
+				continue
+			}
+			priv.Pair = items[j].Path
+			if items[j].Pair == "" {
+				items[j].Pair = priv.Path
+			}
+			break
+		}
+	}
+}
+
+// classifyItem records weak algorithms, age and permission problems.
+func classifyItem(it *inventoryItem, policy scanPolicy) {
+	switch {
+	case it.Algorithm == ssh.KeyAlgoDSA:
+		it.Problems = append(it.Problems, "DSA key (deprecated)")
+	case it.Algorithm == ssh.KeyAlgoRSA && it.Bits > 0 && it.Bits < policy.minRSABits:
+		it.Problems = append(it.Problems, fmt.Sprintf("RSA key of %d bits is below %d", it.Bits, policy.minRSABits))
+	}
+	if policy.maxAgeDays > 0 && it.AgeDays > policy.maxAgeDays && it.Kind != kindCertificate && it.Kind != kindKnownHosts {
+		it.Problems = append(it.Problems, fmt.Sprintf("not changed for %d days", it.AgeDays))
+	}
+	if it.NotAfter != nil && it.NotAfter.Before(time.Now()) {
+		it.Problems = append(it.Problems, "certificate expired "+it.NotAfter.Format("2006-01-02"))
+	}
+	if it.Kind == kindPrivate && it.Fingerprint == "" && !it.Encrypted {
+		it.Problems = append(it.Problems, "unreadable private key")
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	mode, err := strconv.ParseUint(it.Mode, 8, 32)
+	if err != nil {
+		return
+	}
+	switch it.Kind {
+	case kindPrivate:
+		if mode&0o077 != 0 {
+			it.Problems = append(it.Problems, "private key readable by group or others")
+		}
+	default:
+		if mode&0o022 != 0 {
+			it.Problems = append(it.Problems, "writable by group or others")
+		}
+	}
+}
+
+// Run the scan subcommand
+func runScan(args []string) error {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	format := fs.String("format", "table", "output format: table, json or csv")
+	out := fs.String("o", "", "output file (default: stdout)")
+	host := fs.String("host", "", "host name recorded for each key (default: this host)")
+	minRSA := fs.Int("min-rsa", 3072, "smallest acceptable RSA key size")
+	maxAge := fs.Int("max-age", 365, "flag keys not changed for more than this many days (0 = off)")
+	save := fs.String("save", "", "also save the inventory as a JSON snapshot for drift")
+	quiet := fs.Bool("q", false, "do not print unreadable paths")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s scan [flags] <dir|file>... (default: ~/.ssh)\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	roots := fs.Args()
+	if len(roots) == 0 {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		roots = []string{filepath.Join(home, ".ssh")}
+	}
+	if *host == "" {
+		*host, _ = os.Hostname()
+	}
+
+	warn := func(err error) {
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
+	inv := scanPaths(roots, *host, scanPolicy{minRSABits: *minRSA, maxAgeDays: *maxAge}, warn)
+	if *save != "" {
+		if err := writeInventoryOutput(inv, "json", *save); err != nil {
+			return err
+		}
+	}
+	return writeInventoryOutput(inv, *format, *out)
+}
+
+// readInventory loads an inventory saved by scan -format json.
+func readInventory(path string) (*inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var inv inventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i := range inv.Items {
+		it := &inv.Items[i]
+		if it.Host == "" {
+			it.Host = inv.Host
+		}
+		if it.PublicKey != "" {
+			if pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(it.PublicKey)); err == nil {
+				it.key = pub
+			}
+		}
+	}
+	return &inv, nil
+}
+
+// writeInventoryOutput writes an inventory to path (stdout when empty).
+func writeInventoryOutput(inv *inventory, format, path string) error {
+	var buf bytes.Buffer
+	switch format {
+	case "table":
+		writeInventoryTable(&buf, inv.Items)
+	case "json":
+		data, err := json.MarshalIndent(inv, "", "  ")
+		if err != nil {
+			return err
+		}
+		buf.Write(append(data, '\n'))
+	case "csv":
+		if err := writeInventoryCSV(&buf, inv.Items); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format %q (table, json or csv)", format)
+	}
+	if path == "" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), 0o644)
+}
+
+// sortInventoryItems orders items by host, path and line.
+func sortInventoryItems(items []inventoryItem) {
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Host != items[j].Host {
+			return items[i].Host < items[j].Host
+		}
+		if items[i].Path != items[j].Path {
+			return items[i].Path < items[j].Path
+		}
+		return items[i].Line < items[j].Line
+	})
+}
+
+// writeInventoryTable prints one aligned row per key.
+func writeInventoryTable(w io.Writer, items []inventoryItem) {
+	sortInventoryItems(items)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tKIND\tALGORITHM\tBITS\tENCRYPTED\tMODE\tAGE\tFINGERPRINT\tPROBLEMS")
+	for _, it := range items {
+		path := it.Path
+		if it.Line > 0 {
+			path += ":" + strconv.Itoa(it.Line)
+		}
+		encrypted := "-"
+		if it.Kind == kindPrivate {
+			encrypted = map[bool]string{true: "yes", false: "no"}[it.Encrypted]
+		}
+		problems := strings.Join(it.Problems, "; ")
+		if problems == "" {
+			problems = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%dd\t%s\t%s\n",
+			path, it.Kind, it.Algorithm, it.Bits, encrypted, it.Mode, it.AgeDays, it.Fingerprint, problems)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n%d keys found\n", len(items))
+}
+
+// writeInventoryCSV writes the inventory as CSV with a header row.
+func writeInventoryCSV(w io.Writer, items []inventoryItem) error {
+	sortInventoryItems(items)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"host", "path", "line", "kind", "format", "algorithm", "bits", "fingerprint", "comment", "encrypted", "mode", "modified", "age_days", "pair", "problems"})
+	for _, it := range items {
+		cw.Write([]string{
+			it.Host, it.Path, strconv.Itoa(it.Line), it.Kind, it.Format, it.Algorithm, strconv.Itoa(it.Bits),
+			it.Fingerprint, it.Comment, strconv.FormatBool(it.Encrypted), it.Mode,
+			it.Modified.Format(time.RFC3339), strconv.Itoa(it.AgeDays), it.Pair, strings.Join(it.Problems, "; "),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
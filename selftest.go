@@ -0,0 +1,146 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : selftest.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 21:37:50
+ * Description  : Self-test and rollback of freshly written key pairs
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Contents of a key file before it was overwritten, for rolling back
+type keyFileBackup struct {
+	path   string
+	data   []byte
+	perm   os.FileMode
+	exists bool
+}
+
+// backupKeyFile remembers the current contents of path, if any.
+func backupKeyFile(path string) (keyFileBackup, error) {
+	b := keyFileBackup{path: path}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return b, err
+	}
+	if b.data, err = os.ReadFile(path); err != nil {
+		return b, err
+	}
+	b.perm, b.exists = info.Mode().Perm(), true
+	return b, nil
+}
+
+// restore puts the previous contents back, or removes a file that did not
+// exist before.
+func (b keyFileBackup) restore() error {
+	if b.path == "" {
+		return nil
+	}
+	if !b.exists {
+		if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return writeFileAtomic(b.path, b.data, b.perm)
+}
+
+// rollbackKeyFiles restores every backup and adds any failure to err.
+func rollbackKeyFiles(err error, backups ...keyFileBackup) error {
+	for _, b := range backups {
+		if rerr := b.restore(); rerr != nil {
+			return fmt.Errorf("%w (rolling back %s failed: %v)", err, b.path, rerr)
+		}
+	}
+	return fmt.Errorf("%w; previous files restored", err)
+}
+
+// selfTestKeyPair re-reads a written key pair, parses the private key as an
+// SSH signer and the public key file, signs a random challenge and verifies
+// it, and checks the file modes are 0600 and 0644. It returns a summary for
+// display.
+func selfTestKeyPair(privatePath, publicPath string, passphrase []byte) (string, error) {
+	privData, err := os.ReadFile(privatePath)
+	if err != nil {
+		return "", err
+	}
+	var signer ssh.Signer
+	if len(passphrase) > 0 {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(privData, passphrase)
+	} else {
+		signer, err = ssh.ParsePrivateKey(privData)
+	}
+	if err != nil {
+		return "", fmt.Errorf("self-test: parsing %s: %w", privatePath, err)
+	}
+
+	pubData, err := os.ReadFile(publicPath)
+	if err != nil {
+		return "", err
+	}
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(pubData)
+	if err != nil {
+		return "", fmt.Errorf("self-test: parsing %s: %w", publicPath, err)
+	}
+	if !keysEqual(signer.PublicKey(), pub) {
+		return "", fmt.Errorf("self-test: %s does not match %s", publicPath, privatePath)
+	}
+
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", err
+	}
+	var sig *ssh.Signature
+	if algSigner, ok := signer.(ssh.AlgorithmSigner); ok && pub.Type() == ssh.KeyAlgoRSA {
+		// Servers no longer accept SHA-1 RSA signatures
+		sig, err = algSigner.SignWithAlgorithm(rand.Reader, challenge, ssh.KeyAlgoRSASHA256)
+	} else {
+		sig, err = signer.Sign(rand.Reader, challenge)
+	}
+	if err != nil {
+		return "", fmt.Errorf("self-test: signing challenge: %w", err)
+	}
+	if err := pub.Verify(challenge, sig); err != nil {
+		return "", fmt.Errorf("self-test: verifying challenge signature: %w", err)
+	}
+
+	// Windows does not keep Unix permission bits
+	if runtime.GOOS != "windows" {
+		for _, f := range []struct {
+			path string
+			perm os.FileMode
+		}{{privatePath, 0o600}, {publicPath, 0o644}} {
+			info, err := os.Stat(f.path)
+			if err != nil {
+				return "", err
+			}
+			if info.Mode().Perm() != f.perm {
+				return "", fmt.Errorf("self-test: %s has mode %04o, want %04o", f.path, info.Mode().Perm(), f.perm)
+			}
+		}
+	}
+
+	return fmt.Sprintf("%s challenge signed and verified with %s", sig.Format, ssh.FingerprintSHA256(pub)), nil
+}
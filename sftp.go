@@ -0,0 +1,455 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : sftp.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 23:02:44
+ * Description  : Minimal SFTP version 3 client for the remote file operations of copy-id
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTP packet types
+const (
+	sftpInit    = 1
+	sftpVersion = 2
+	sftpOpen    = 3
+	sftpClose   = 4
+	sftpRead    = 5
+	sftpWrite   = 6
+	sftpFstat   = 8
+	sftpSetstat = 9
+	sftpMkdir   = 14
+	sftpStat    = 17
+	sftpStatus  = 101
+	sftpHandle  = 102
+	sftpData    = 103
+	sftpAttrs   = 105
+)
+
+// SFTP open flags
+const (
+	sftpFlagRead   = 0x01
+	sftpFlagWrite  = 0x02
+	sftpFlagAppend = 0x04
+	sftpFlagCreate = 0x08
+)
+
+// SFTP attribute flags
+const (
+	sftpAttrSize        = 0x01
+	sftpAttrUIDGID      = 0x02
+	sftpAttrPermissions = 0x04
+	sftpAttrACModTime   = 0x08
+	sftpAttrExtended    = 0x80000000
+)
+
+// SFTP status codes
+const (
+	sftpStatusOK               = 0
+	sftpStatusEOF              = 1
+	sftpStatusNoSuchFile       = 2
+	sftpStatusPermissionDenied = 3
+)
+
+const (
+	sftpMaxPacket = 256 * 1024 // largest packet accepted from the peer
+	sftpChunkSize = 32 * 1024  // read and write size per request
+)
+
+// Unix file type bits carried in the permissions attribute
+const (
+	sftpModeType = 0170000
+	sftpModeDir  = 0040000
+	sftpModeReg  = 0100000
+)
+
+// File attributes used by copy-id
+type sftpFileAttrs struct {
+	flags uint32
+	size  uint64
+	perm  uint32
+}
+
+// mode converts the permissions attribute to an os.FileMode.
+func (a sftpFileAttrs) mode() os.FileMode {
+	mode := os.FileMode(a.perm & 0777)
+	switch a.perm & sftpModeType {
+	case sftpModeDir:
+		mode |= os.ModeDir
+	case sftpModeReg, 0:
+	default:
+		mode |= os.ModeIrregular
+	}
+	return mode
+}
+
+// appendTo encodes the size and permissions attributes.
+func (a sftpFileAttrs) appendTo(b []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, a.flags)
+	if a.flags&sftpAttrSize != 0 {
+		b = binary.BigEndian.AppendUint64(b, a.size)
+	}
+	if a.flags&sftpAttrPermissions != 0 {
+		b = binary.BigEndian.AppendUint32(b, a.perm)
+	}
+	return b
+}
+
+// Decoder for the fields of an SFTP packet; the first error sticks
+type sftpBuffer struct {
+	data []byte
+	err  error
+}
+
+func (b *sftpBuffer) take(n int) []byte {
+	if b.err != nil {
+		return nil
+	}
+	if n < 0 || len(b.data) < n {
+		b.err = errors.New("sftp: short packet")
+		return nil
+	}
+	v := b.data[:n]
+	b.data = b.data[n:]
+	return v
+}
+
+func (b *sftpBuffer) uint32() uint32 {
+	if v := b.take(4); v != nil {
+		return binary.BigEndian.Uint32(v)
+	}
+	return 0
+}
+
+func (b *sftpBuffer) uint64() uint64 {
+	if v := b.take(8); v != nil {
+		return binary.BigEndian.Uint64(v)
+	}
+	return 0
+}
+
+func (b *sftpBuffer) string() []byte {
+	return b.take(int(b.uint32()))
+}
+
+func (b *sftpBuffer) attrs() sftpFileAttrs {
+	a := sftpFileAttrs{flags: b.uint32()}
+	if a.flags&sftpAttrSize != 0 {
+		a.size = b.uint64()
+	}
+	if a.flags&sftpAttrUIDGID != 0 {
+		b.take(8)
+	}
+	if a.flags&sftpAttrPermissions != 0 {
+		a.perm = b.uint32()
+	}
+	if a.flags&sftpAttrACModTime != 0 {
+		b.take(8)
+	}
+	if a.flags&sftpAttrExtended != 0 {
+		for n := b.uint32(); n > 0 && b.err == nil; n-- {
+			b.string()
+			b.string()
+		}
+	}
+	return a
+}
+
+// appendSFTPString encodes a length-prefixed string.
+func appendSFTPString(b, s []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// writeSFTPPacket sends one packet of the given type.
+func writeSFTPPacket(w io.Writer, typ byte, payload []byte) error {
+	packet := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+1))
+	packet = append(packet, typ)
+	_, err := w.Write(append(packet, payload...))
+	return err
+}
+
+// readSFTPPacket reads one packet and returns its type and payload.
+func readSFTPPacket(r io.Reader) (byte, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(header[:])
+	if n == 0 || n > sftpMaxPacket {
+		return 0, nil, fmt.Errorf("sftp: invalid packet length %d", n)
+	}
+	packet := make([]byte, n)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return 0, nil, err
+	}
+	return packet[0], packet[1:], nil
+}
+
+// Client for the sftp subsystem of one SSH session. Requests are sent one at
+// a time.
+type sftpClient struct {
+	session *ssh.Session
+	w       io.WriteCloser
+	r       io.Reader
+	nextID  uint32
+}
+
+// newSFTPClient starts the sftp subsystem and negotiates version 3.
+func newSFTPClient(client *ssh.Client) (*sftpClient, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	w, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	c := &sftpClient{session: session, w: w, r: r}
+	if err := writeSFTPPacket(w, sftpInit, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		c.Close()
+		return nil, err
+	}
+	typ, _, err := readSFTPPacket(r)
+	if err == nil && typ != sftpVersion {
+		err = fmt.Errorf("sftp: unexpected packet type %d", typ)
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close ends the subsystem session.
+func (c *sftpClient) Close() error {
+	c.w.Close()
+	return c.session.Close()
+}
+
+// request sends a packet with a new request id and returns the response.
+func (c *sftpClient) request(typ byte, payload []byte) (byte, *sftpBuffer, error) {
+	c.nextID++
+	id := c.nextID
+	if err := writeSFTPPacket(c.w, typ, append(binary.BigEndian.AppendUint32(nil, id), payload...)); err != nil {
+		return 0, nil, err
+	}
+	rtyp, data, err := readSFTPPacket(c.r)
+	if err != nil {
+		return 0, nil, err
+	}
+	b := &sftpBuffer{data: data}
+	if b.uint32() != id || b.err != nil {
+		return 0, nil, errors.New("sftp: response does not match request")
+	}
+	return rtyp, b, nil
+}
+
+// statusError converts a status response to an error, nil for success.
+func statusError(op, name string, typ byte, b *sftpBuffer) error {
+	if typ != sftpStatus {
+		return fmt.Errorf("sftp: %s %s: unexpected packet type %d", op, name, typ)
+	}
+	code, msg := b.uint32(), string(b.string())
+	switch code {
+	case sftpStatusOK:
+		return nil
+	case sftpStatusNoSuchFile:
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	case sftpStatusPermissionDenied:
+		return &os.PathError{Op: op, Path: name, Err: os.ErrPermission}
+	}
+	if msg == "" {
+		msg = fmt.Sprintf("status %d", code)
+	}
+	return &os.PathError{Op: op, Path: name, Err: errors.New(msg)}
+}
+
+// attrsResponse decodes an attributes response.
+func attrsResponse(op, name string, typ byte, b *sftpBuffer) (sftpFileAttrs, error) {
+	if typ != sftpAttrs {
+		if err := statusError(op, name, typ, b); err != nil {
+			return sftpFileAttrs{}, err
+		}
+		return sftpFileAttrs{}, fmt.Errorf("sftp: %s %s: no attributes returned", op, name)
+	}
+	a := b.attrs()
+	return a, b.err
+}
+
+// Stat returns the mode of name, following symbolic links.
+func (c *sftpClient) Stat(name string) (os.FileMode, error) {
+	typ, b, err := c.request(sftpStat, appendSFTPString(nil, []byte(name)))
+	if err != nil {
+		return 0, err
+	}
+	a, err := attrsResponse("stat", name, typ, b)
+	if err != nil {
+		return 0, err
+	}
+	if a.flags&sftpAttrPermissions == 0 {
+		return 0, fmt.Errorf("sftp: stat %s: server did not return permissions", name)
+	}
+	return a.mode(), nil
+}
+
+// Mkdir creates the directory name with the given permissions.
+func (c *sftpClient) Mkdir(name string, perm os.FileMode) error {
+	payload := appendSFTPString(nil, []byte(name))
+	payload = sftpFileAttrs{flags: sftpAttrPermissions, perm: uint32(perm.Perm())}.appendTo(payload)
+	typ, b, err := c.request(sftpMkdir, payload)
+	if err != nil {
+		return err
+	}
+	return statusError("mkdir", name, typ, b)
+}
+
+// Chmod sets the permissions of name.
+func (c *sftpClient) Chmod(name string, perm os.FileMode) error {
+	payload := appendSFTPString(nil, []byte(name))
+	payload = sftpFileAttrs{flags: sftpAttrPermissions, perm: uint32(perm.Perm())}.appendTo(payload)
+	typ, b, err := c.request(sftpSetstat, payload)
+	if err != nil {
+		return err
+	}
+	return statusError("chmod", name, typ, b)
+}
+
+// open returns a handle for name.
+func (c *sftpClient) open(name string, flags uint32, perm os.FileMode) ([]byte, error) {
+	payload := appendSFTPString(nil, []byte(name))
+	payload = binary.BigEndian.AppendUint32(payload, flags)
+	attrs := sftpFileAttrs{}
+	if flags&sftpFlagCreate != 0 {
+		attrs = sftpFileAttrs{flags: sftpAttrPermissions, perm: uint32(perm.Perm())}
+	}
+	payload = attrs.appendTo(payload)
+	typ, b, err := c.request(sftpOpen, payload)
+	if err != nil {
+		return nil, err
+	}
+	if typ != sftpHandle {
+		if err := statusError("open", name, typ, b); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("sftp: open %s: no handle returned", name)
+	}
+	handle := b.string()
+	return handle, b.err
+}
+
+// close releases a handle.
+func (c *sftpClient) close(name string, handle []byte) error {
+	typ, b, err := c.request(sftpClose, appendSFTPString(nil, handle))
+	if err != nil {
+		return err
+	}
+	return statusError("close", name, typ, b)
+}
+
+// ReadFile returns the contents of name.
+func (c *sftpClient) ReadFile(name string) ([]byte, error) {
+	handle, err := c.open(name, sftpFlagRead, 0)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for {
+		payload := appendSFTPString(nil, handle)
+		payload = binary.BigEndian.AppendUint64(payload, uint64(len(data)))
+		payload = binary.BigEndian.AppendUint32(payload, sftpChunkSize)
+		typ, b, err := c.request(sftpRead, payload)
+		if err != nil {
+			return nil, err
+		}
+		if typ == sftpData {
+			chunk := b.string()
+			if b.err != nil {
+				return nil, b.err
+			}
+			data = append(data, chunk...)
+			continue
+		}
+		if typ == sftpStatus {
+			probe := *b
+			if probe.uint32() == sftpStatusEOF {
+				break
+			}
+		}
+		err = statusError("read", name, typ, b)
+		if err == nil {
+			err = fmt.Errorf("sftp: read %s: unexpected success status", name)
+		}
+		c.close(name, handle)
+		return nil, err
+	}
+	return data, c.close(name, handle)
+}
+
+// AppendFile writes data at the end of name, creating it with perm when
+// missing.
+func (c *sftpClient) AppendFile(name string, data []byte, perm os.FileMode) error {
+	handle, err := c.open(name, sftpFlagWrite|sftpFlagAppend|sftpFlagCreate, perm)
+	if err != nil {
+		return err
+	}
+	// Servers that ignore the append flag write at the given offset, so start
+	// at the current end of the file.
+	typ, b, err := c.request(sftpFstat, appendSFTPString(nil, handle))
+	if err != nil {
+		return err
+	}
+	attrs, err := attrsResponse("stat", name, typ, b)
+	if err != nil {
+		c.close(name, handle)
+		return err
+	}
+	offset := attrs.size
+	for len(data) > 0 {
+		n := min(len(data), sftpChunkSize)
+		payload := appendSFTPString(nil, handle)
+		payload = binary.BigEndian.AppendUint64(payload, offset)
+		payload = appendSFTPString(payload, data[:n])
+		typ, b, err := c.request(sftpWrite, payload)
+		if err != nil {
+			return err
+		}
+		if err := statusError("write", name, typ, b); err != nil {
+			c.close(name, handle)
+			return err
+		}
+		offset += uint64(n)
+		data = data[n:]
+	}
+	return c.close(name, handle)
+}
@@ -0,0 +1,390 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : sshsig.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 14:31:02
+ * Description  : OpenSSH SSHSIG file signing and verification (ssh-keygen -Y)
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHSIG format constants (see OpenSSH PROTOCOL.sshsig)
+const (
+	sshsigMagic   = "SSHSIG"
+	sshsigVersion = 1
+	sshsigBegin   = "-----BEGIN SSH SIGNATURE-----"
+	sshsigEnd     = "-----END SSH SIGNATURE-----"
+	sshsigWrap    = 70
+)
+
+// Parsed SSHSIG signature
+type sshSignature struct {
+	publicKey     ssh.PublicKey
+	namespace     string
+	hashAlgorithm string
+	signature     *ssh.Signature
+}
+
+// Wire layout of the signature blob after the magic preamble
+type sshsigBlob struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// Wire layout of the data that is actually signed, after the magic preamble
+type sshsigSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// sshsigHash returns the hash function for an SSHSIG hash algorithm name.
+func sshsigHash(name string) (hash.Hash, error) {
+	switch name {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported signature hash algorithm %q", name)
+	}
+}
+
+// sshsigMessage builds the byte string signed for a message digest.
+func sshsigMessage(namespace, hashAlgorithm string, message io.Reader) ([]byte, error) {
+	h, err := sshsigHash(hashAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(h, message); err != nil {
+		return nil, err
+	}
+	data := ssh.Marshal(sshsigSignedData{
+		Namespace:     namespace,
+		HashAlgorithm: hashAlgorithm,
+		Hash:          h.Sum(nil),
+	})
+	return append([]byte(sshsigMagic), data...), nil
+}
+
+// signSSHSIG signs a message in namespace and returns the armored signature.
+func signSSHSIG(signer ssh.Signer, namespace, hashAlgorithm string, message io.Reader) ([]byte, error) {
+	if namespace == "" {
+		return nil, errors.New("a signature namespace is required")
+	}
+	data, err := sshsigMessage(namespace, hashAlgorithm, message)
+	if err != nil {
+		return nil, err
+	}
+
+	var sig *ssh.Signature
+	if signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		// SSHSIG forbids SHA-1 RSA signatures
+		algSigner, ok := signer.(ssh.AlgorithmSigner)
+		if !ok {
+			return nil, errors.New("RSA signer does not support rsa-sha2-512")
+		}
+		sig, err = algSigner.SignWithAlgorithm(rand.Reader, data, ssh.KeyAlgoRSASHA512)
+	} else {
+		sig, err = signer.Sign(rand.Reader, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	blob := ssh.Marshal(sshsigBlob{
+		Version:       sshsigVersion,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     namespace,
+		HashAlgorithm: hashAlgorithm,
+		Signature:     ssh.Marshal(sig),
+	})
+	return armorSSHSIG(append([]byte(sshsigMagic), blob...)), nil
+}
+
+// armorSSHSIG wraps a signature blob in the BEGIN/END SSH SIGNATURE armor.
+func armorSSHSIG(blob []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var b bytes.Buffer
+	b.WriteString(sshsigBegin + "\n")
+	for len(encoded) > sshsigWrap {
+		b.WriteString(encoded[:sshsigWrap] + "\n")
+		encoded = encoded[sshsigWrap:]
+	}
+	b.WriteString(encoded + "\n")
+	b.WriteString(sshsigEnd + "\n")
+	return b.Bytes()
+}
+
+// parseSSHSIG decodes an armored SSHSIG signature.
+func parseSSHSIG(armored []byte) (*sshSignature, error) {
+	text := strings.TrimSpace(strings.ReplaceAll(string(armored), "\r\n", "\n"))
+	if !strings.HasPrefix(text, sshsigBegin) || !strings.HasSuffix(text, sshsigEnd) {
+		return nil, errors.New("not an SSH signature (missing BEGIN/END SSH SIGNATURE armor)")
+	}
+	body := strings.Join(strings.Fields(text[len(sshsigBegin):len(text)-len(sshsigEnd)]), "")
+	raw, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if !bytes.HasPrefix(raw, []byte(sshsigMagic)) {
+		return nil, errors.New("invalid signature magic")
+	}
+
+	var blob sshsigBlob
+	if err := ssh.Unmarshal(raw[len(sshsigMagic):], &blob); err != nil {
+		return nil, fmt.Errorf("invalid signature blob: %w", err)
+	}
+	if blob.Version != sshsigVersion {
+		return nil, fmt.Errorf("unsupported signature version %d", blob.Version)
+	}
+	pub, err := ssh.ParsePublicKey(blob.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature key: %w", err)
+	}
+	sig := new(ssh.Signature)
+	if err := ssh.Unmarshal(blob.Signature, sig); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	return &sshSignature{
+		publicKey:     pub,
+		namespace:     blob.Namespace,
+		hashAlgorithm: blob.HashAlgorithm,
+		signature:     sig,
+	}, nil
+}
+
+// verify checks the signature over message in the expected namespace.
+func (s *sshSignature) verify(namespace string, message io.Reader) error {
+	if s.namespace != namespace {
+		return fmt.Errorf("signature namespace %q does not match %q", s.namespace, namespace)
+	}
+	if s.signature.Format == ssh.KeyAlgoRSA {
+		return errors.New("RSA signatures with SHA-1 are not accepted")
+	}
+	data, err := sshsigMessage(s.namespace, s.hashAlgorithm, message)
+	if err != nil {
+		return err
+	}
+	if err := s.publicKey.Verify(data, s.signature); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+	return nil
+}
+
+// keyDescription returns e.g. "ED25519 key SHA256:..." for messages.
+func (s *sshSignature) keyDescription() string {
+	key := s.publicKey
+	kind := "key"
+	if cert, ok := key.(*ssh.Certificate); ok {
+		key = cert.Key
+		kind = "cert"
+	}
+	name := strings.ToUpper(strings.TrimPrefix(key.Type(), "ssh-"))
+	if strings.HasPrefix(key.Type(), "ecdsa-") {
+		name = "ECDSA"
+	}
+	return fmt.Sprintf("%s %s %s", name, kind, ssh.FingerprintSHA256(key))
+}
+
+// Run the sign subcommand
+func runSign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyFile := fs.String("f", "id_ed25519", "private key used for signing")
+	namespace := fs.String("n", "", "signature namespace (e.g. file, git)")
+	hashAlg := fs.String("hash", "sha512", "message hash algorithm (sha256 or sha512)")
+	passphrase := fs.String("passphrase", "", "passphrase source for an encrypted key (env:, file:, pass:)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s sign -f key -n namespace [file...]\n", filepath.Base(os.Args[0]))
+		fmt.Fprintln(fs.Output(), "Each file is signed to <file>.sig; without files standard input is signed to standard output.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	priv, err := loadPrivateKey(*keyFile, *passphrase)
+	if err != nil {
+		return err
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		sig, err := signSSHSIG(signer, *namespace, *hashAlg, os.Stdin)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(sig)
+		return err
+	}
+
+	for _, path := range fs.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		sig, err := signSSHSIG(signer, *namespace, *hashAlg, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := writeFileAtomic(path+".sig", sig, 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Signed %s to %s\n", path, path+".sig")
+	}
+	return nil
+}
+
+// Run the verify subcommand
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	signersFile := fs.String("f", "allowed_signers", "allowed_signers file")
+	identity := fs.String("I", "", "signer identity (principal) to verify")
+	namespace := fs.String("n", "", "expected signature namespace")
+	sigFile := fs.String("s", "", "signature file")
+	verifyTime := fs.String("verify-time", "", "check key validity at this time (YYYYMMDD[HHMM[SS]][Z]) instead of now")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s verify -f allowed_signers -I identity -n namespace -s file.sig < file\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *identity == "" || *namespace == "" || *sigFile == "" {
+		fs.Usage()
+		return errors.New("-I, -n and -s are required")
+	}
+
+	at := time.Now()
+	if *verifyTime != "" {
+		var err error
+		if at, err = parseOpenSSHTime(*verifyTime); err != nil {
+			return err
+		}
+	}
+
+	sig, err := readSSHSIGFile(*sigFile)
+	if err != nil {
+		return err
+	}
+	if err := sig.verify(*namespace, os.Stdin); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(*signersFile)
+	if err != nil {
+		return err
+	}
+	signers, _ := parseAllowedSigners(data)
+	for _, s := range signers {
+		if matchPatternList(*identity, s.principals) && s.matchesKey(sig.publicKey, *identity, at) &&
+			s.allowsNamespace(*namespace) && s.validAt(at) {
+			fmt.Printf("Good %q signature for %s with %s\n", *namespace, *identity, sig.keyDescription())
+			return nil
+		}
+	}
+	return fmt.Errorf("signature by %s is not authorized for %s in %s", sig.keyDescription(), *identity, *signersFile)
+}
+
+// Run the find-principals subcommand
+func runFindPrincipals(args []string) error {
+	fs := flag.NewFlagSet("find-principals", flag.ExitOnError)
+	signersFile := fs.String("f", "allowed_signers", "allowed_signers file")
+	sigFile := fs.String("s", "", "signature file")
+	fs.Parse(args)
+	if *sigFile == "" {
+		fs.Usage()
+		return errors.New("-s is required")
+	}
+
+	sig, err := readSSHSIGFile(*sigFile)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(*signersFile)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	signers, _ := parseAllowedSigners(data)
+	found := false
+	for _, s := range signers {
+		if s.matchesKey(sig.publicKey, "", now) && s.validAt(now) {
+			fmt.Println(strings.Join(s.principals, ","))
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("no principal matched %s", sig.keyDescription())
+	}
+	return nil
+}
+
+// Run the check-novalidate subcommand
+func runCheckNoValidate(args []string) error {
+	fs := flag.NewFlagSet("check-novalidate", flag.ExitOnError)
+	namespace := fs.String("n", "", "expected signature namespace")
+	sigFile := fs.String("s", "", "signature file")
+	fs.Parse(args)
+	if *namespace == "" || *sigFile == "" {
+		fs.Usage()
+		return errors.New("-n and -s are required")
+	}
+
+	sig, err := readSSHSIGFile(*sigFile)
+	if err != nil {
+		return err
+	}
+	if err := sig.verify(*namespace, os.Stdin); err != nil {
+		return err
+	}
+	fmt.Printf("Good %q signature with %s\n", *namespace, sig.keyDescription())
+	return nil
+}
+
+// readSSHSIGFile reads and parses an armored signature file.
+func readSSHSIGFile(path string) (*sshSignature, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := parseSSHSIG(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sig, nil
+}
@@ -0,0 +1,370 @@
+/*
+ **********************************************************************
+ * -------------------------------------------------------------------
+ * Project Name : Abdal 4iProto Server SSH KeyGen
+ * File Name    : x509cert.go
+ * Author       : Ebrahim Shafiei (EbraSha)
+ * Email        : Prof.Shafiei@Gmail.com
+ * Created On   : 2026-10-18 17:31:48
+ * Description  : PKCS#10 certificate requests and self-signed X.509 certificates
+ * -------------------------------------------------------------------
+ *
+ * "Coding is an engaging and beloved hobby for me. I passionately and insatiably pursue knowledge in cybersecurity and programming."
+ * – Ebrahim Shafiei
+ *
+ **********************************************************************
+ */
+
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Key usage names accepted by -key-usage
+var x509KeyUsages = map[string]x509.KeyUsage{
+	"digitalSignature":  x509.KeyUsageDigitalSignature,
+	"contentCommitment": x509.KeyUsageContentCommitment,
+	"keyEncipherment":   x509.KeyUsageKeyEncipherment,
+	"dataEncipherment":  x509.KeyUsageDataEncipherment,
+	"keyAgreement":      x509.KeyUsageKeyAgreement,
+	"keyCertSign":       x509.KeyUsageCertSign,
+	"cRLSign":           x509.KeyUsageCRLSign,
+	"encipherOnly":      x509.KeyUsageEncipherOnly,
+	"decipherOnly":      x509.KeyUsageDecipherOnly,
+}
+
+// Extended key usage names accepted by -ext-key-usage, with their OIDs
+var x509ExtKeyUsages = map[string]struct {
+	usage x509.ExtKeyUsage
+	oid   asn1.ObjectIdentifier
+}{
+	"serverAuth":      {x509.ExtKeyUsageServerAuth, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 1}},
+	"clientAuth":      {x509.ExtKeyUsageClientAuth, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 2}},
+	"codeSigning":     {x509.ExtKeyUsageCodeSigning, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 3}},
+	"emailProtection": {x509.ExtKeyUsageEmailProtection, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 4}},
+	"timeStamping":    {x509.ExtKeyUsageTimeStamping, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}},
+	"OCSPSigning":     {x509.ExtKeyUsageOCSPSigning, asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 9}},
+}
+
+// Extension OIDs written into CSRs
+var (
+	oidExtKeyUsage    = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtExtKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+)
+
+// Options shared by csr and cert
+type x509Options struct {
+	keyFile     string
+	passphrase  string
+	subject     string
+	dnsNames    stringList
+	ipAddresses stringList
+	keyUsage    string
+	extKeyUsage string
+	out         string
+	force       bool
+}
+
+// register adds the shared flags to fs.
+func (o *x509Options) register(fs *flag.FlagSet, defaultKU, defaultEKU string) {
+	fs.StringVar(&o.keyFile, "f", "id_rsa", "private key file")
+	fs.StringVar(&o.passphrase, "passphrase", "", "passphrase source for an encrypted key (default: prompt)")
+	fs.StringVar(&o.subject, "subject", "", `subject, e.g. "CN=tunnel.example.com,O=Example,C=US" or "/CN=tunnel.example.com/O=Example"`)
+	fs.Var(&o.dnsNames, "dns", "DNS subject alternative name (repeatable)")
+	fs.Var(&o.ipAddresses, "ip", "IP subject alternative name (repeatable)")
+	fs.StringVar(&o.keyUsage, "key-usage", defaultKU, "comma separated key usages (digitalSignature, keyEncipherment, keyAgreement, keyCertSign, cRLSign, ...)")
+	fs.StringVar(&o.extKeyUsage, "ext-key-usage", defaultEKU, "comma separated extended key usages (serverAuth, clientAuth, codeSigning, emailProtection, timeStamping, OCSPSigning)")
+	fs.StringVar(&o.out, "o", "", "output file")
+	fs.BoolVar(&o.force, "force", false, "overwrite the output file")
+}
+
+// x509Request holds the parsed shared options.
+type x509Request struct {
+	signer      crypto.Signer
+	subject     pkix.Name
+	dnsNames    []string
+	ips         []net.IP
+	keyUsage    x509.KeyUsage
+	extKeyUsage []x509.ExtKeyUsage
+	extOIDs     []asn1.ObjectIdentifier
+}
+
+// load reads the key and validates the subject, SANs and usages.
+func (o *x509Options) load() (*x509Request, error) {
+	priv, err := loadPrivateKey(o.keyFile, o.passphrase)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", priv)
+	}
+	r := &x509Request{signer: signer, dnsNames: o.dnsNames}
+
+	if r.subject, err = parseDistinguishedName(o.subject); err != nil {
+		return nil, err
+	}
+	if r.subject.CommonName == "" && len(r.dnsNames) > 0 {
+		r.subject.CommonName = r.dnsNames[0]
+	}
+	if r.subject.CommonName == "" && len(r.dnsNames) == 0 && len(o.ipAddresses) == 0 {
+		return nil, errors.New("a subject CN, -dns or -ip is required")
+	}
+	for _, s := range o.ipAddresses {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", s)
+		}
+		r.ips = append(r.ips, ip)
+	}
+
+	for _, name := range splitOptionList(o.keyUsage) {
+		ku, ok := x509KeyUsages[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown key usage %q", name)
+		}
+		if _, isRSA := signer.(*rsa.PrivateKey); !isRSA && (ku == x509.KeyUsageKeyEncipherment || ku == x509.KeyUsageDataEncipherment) {
+			return nil, fmt.Errorf("key usage %s requires an RSA key", name)
+		}
+		r.keyUsage |= ku
+	}
+	for _, name := range splitOptionList(o.extKeyUsage) {
+		eku, ok := x509ExtKeyUsages[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown extended key usage %q", name)
+		}
+		r.extKeyUsage = append(r.extKeyUsage, eku.usage)
+		r.extOIDs = append(r.extOIDs, eku.oid)
+	}
+	return r, nil
+}
+
+// parseDistinguishedName parses "CN=a,O=b" or "/CN=a/O=b". A backslash
+// escapes the separator.
+func parseDistinguishedName(s string) (pkix.Name, error) {
+	var name pkix.Name
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return name, nil
+	}
+	sep := ','
+	if strings.HasPrefix(s, "/") {
+		sep, s = '/', s[1:]
+	}
+
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			cur.WriteByte(s[i])
+		case rune(s[i]) == sep:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(s[i])
+		}
+	}
+	parts = append(parts, cur.String())
+
+	for _, part := range parts {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			return name, fmt.Errorf("invalid subject component %q", part)
+		}
+		switch strings.ToUpper(strings.TrimSpace(key)) {
+		case "CN":
+			name.CommonName = value
+		case "O":
+			name.Organization = append(name.Organization, value)
+		case "OU":
+			name.OrganizationalUnit = append(name.OrganizationalUnit, value)
+		case "C":
+			name.Country = append(name.Country, value)
+		case "ST":
+			name.Province = append(name.Province, value)
+		case "L":
+			name.Locality = append(name.Locality, value)
+		case "STREET":
+			name.StreetAddress = append(name.StreetAddress, value)
+		case "POSTALCODE":
+			name.PostalCode = append(name.PostalCode, value)
+		case "SERIALNUMBER":
+			name.SerialNumber = value
+		default:
+			return name, fmt.Errorf("unsupported subject attribute %q", key)
+		}
+	}
+	return name, nil
+}
+
+// keyUsageExtension encodes a key usage as a critical X.509 extension.
+func keyUsageExtension(ku x509.KeyUsage) (pkix.Extension, error) {
+	var bits asn1.BitString
+	for i := 0; i < 9; i++ {
+		if ku&(1<<uint(i)) == 0 {
+			continue
+		}
+		for len(bits.Bytes) <= i/8 {
+			bits.Bytes = append(bits.Bytes, 0)
+		}
+		bits.Bytes[i/8] |= 0x80 >> uint(i%8)
+		bits.BitLength = i + 1
+	}
+	value, err := asn1.Marshal(bits)
+	return pkix.Extension{Id: oidExtKeyUsage, Critical: true, Value: value}, err
+}
+
+// writePEMOutput writes a PEM block to path, refusing to overwrite unless forced.
+func writePEMOutput(path, blockType string, der []byte, force bool) error {
+	if !force && fileExists(path) {
+		return fmt.Errorf("%s already exists (use -force to overwrite)", path)
+	}
+	return writeFileAtomic(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o644)
+}
+
+// Run the csr subcommand
+func runCSR(args []string) error {
+	fs := flag.NewFlagSet("csr", flag.ExitOnError)
+	var opts x509Options
+	opts.register(fs, "digitalSignature", "serverAuth")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s csr -f <key> -subject <dn> [-dns name] [-ip addr] [flags]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	r, err := opts.load()
+	if err != nil {
+		return err
+	}
+	template := &x509.CertificateRequest{
+		Subject:     r.subject,
+		DNSNames:    r.dnsNames,
+		IPAddresses: r.ips,
+	}
+	if r.keyUsage != 0 {
+		ext, err := keyUsageExtension(r.keyUsage)
+		if err != nil {
+			return err
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, ext)
+	}
+	if len(r.extOIDs) > 0 {
+		value, err := asn1.Marshal(r.extOIDs)
+		if err != nil {
+			return err
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{Id: oidExtExtKeyUsage, Value: value})
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, r.signer)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return fmt.Errorf("verifying request signature: %w", err)
+	}
+
+	if opts.out == "" {
+		opts.out = opts.keyFile + ".csr"
+	}
+	if err := writePEMOutput(opts.out, "CERTIFICATE REQUEST", der, opts.force); err != nil {
+		return err
+	}
+	fmt.Printf("Certificate request for %s saved to %s\n", csr.Subject, opts.out)
+	return nil
+}
+
+// Run the cert subcommand
+func runCert(args []string) error {
+	fs := flag.NewFlagSet("cert", flag.ExitOnError)
+	var opts x509Options
+	opts.register(fs, "digitalSignature", "serverAuth")
+	days := fs.Int("days", 365, "validity period in days")
+	validFrom := fs.String("valid-from", "", "start of validity as YYYYMMDD[HHMM[SS]][Z] (default: now)")
+	isCA := fs.Bool("ca", false, "create a CA certificate (adds keyCertSign and cRLSign)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s cert -f <key> -subject <dn> [-dns name] [-ip addr] [-days n] [flags]\n", filepath.Base(os.Args[0]))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *days <= 0 {
+		return errors.New("-days must be positive")
+	}
+	r, err := opts.load()
+	if err != nil {
+		return err
+	}
+	notBefore := time.Now().Truncate(time.Second)
+	if *validFrom != "" {
+		if notBefore, err = parseOpenSSHTime(*validFrom); err != nil {
+			return fmt.Errorf("invalid -valid-from: %w", err)
+		}
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               r.subject,
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.AddDate(0, 0, *days),
+		DNSNames:              r.dnsNames,
+		IPAddresses:           r.ips,
+		KeyUsage:              r.keyUsage,
+		ExtKeyUsage:           r.extKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  *isCA,
+	}
+	if *isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, r.signer.Public(), r.signer)
+	if err != nil {
+		return err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+	if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+		return fmt.Errorf("verifying certificate signature: %w", err)
+	}
+
+	if opts.out == "" {
+		opts.out = opts.keyFile + ".crt"
+	}
+	if err := writePEMOutput(opts.out, "CERTIFICATE", der, opts.force); err != nil {
+		return err
+	}
+	fmt.Printf("Self-signed certificate for %s saved to %s\n", cert.Subject, opts.out)
+	fmt.Printf("Valid from %s until %s\n", cert.NotBefore.UTC().Format(time.RFC3339), cert.NotAfter.UTC().Format(time.RFC3339))
+	return nil
+}